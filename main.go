@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 
@@ -24,15 +25,34 @@ func main() {
 	}
 
 	var execute = flag.String("execute", "", "query to execute")
+	var permissive = flag.Bool("permissive", false, "evaluate type mismatches as null rather than failing")
+	var showSecrets = flag.Bool("show-secrets", false, "output the data of secrets rather than redacting it")
+	var parameters = params{}
+	flag.Var(parameters, "p", "parameter value as name=value, for :name, or 1=value, for $1, with an optional type as name:int=value (repeatable)")
 	flag.Parse()
 
+	values, err := parameters.values()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
 	// use the current context in kubeconfig
 	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	results, err := query.ExecuteQuery(config, *execute)
+	prepared, err := query.Prepare(config, *execute, query.Options{
+		Permissive:  *permissive,
+		ShowSecrets: *showSecrets,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	results, err := prepared.Execute(values...)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
@@ -62,6 +82,91 @@ func main() {
 	writer.Flush()
 }
 
+// params are the query parameters given by -p flags. Values are strings,
+// unless they're given a type, as name:type=value.
+type params map[string]interface{}
+
+func (p params) String() string {
+	return ""
+}
+
+func (p params) Set(param string) error {
+	parts := strings.SplitN(param, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected name=value or name:type=value, got %q", param)
+	}
+
+	name, typ := parts[0], "string"
+	if i := strings.Index(name, ":"); i >= 0 {
+		name, typ = name[:i], name[i+1:]
+	}
+
+	val, err := parseParam(typ, parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid %v value for %v, %v", typ, name, err)
+	}
+	p[name] = val
+
+	return nil
+}
+
+// parseParam parses the value of a parameter of a type: string, int, float,
+// bool, or json for any JSON value.
+func parseParam(typ, text string) (interface{}, error) {
+	switch typ {
+	case "string":
+		return text, nil
+	case "int":
+		return strconv.ParseInt(text, 10, 64)
+	case "float":
+		return strconv.ParseFloat(text, 64)
+	case "bool":
+		return strconv.ParseBool(text)
+	case "json":
+		var val interface{}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&val); err != nil {
+			return nil, err
+		}
+		if decoder.More() {
+			return nil, fmt.Errorf("more than one JSON value")
+		}
+		return val, nil
+	}
+
+	return nil, fmt.Errorf("unknown type, expected string, int, float, bool or json")
+}
+
+// values returns the parameters to pass to Execute: numbered parameters in
+// order, followed by named parameters.
+func (p params) values() ([]interface{}, error) {
+	var positional []interface{}
+	var named []interface{}
+	for name, val := range p {
+		n, err := strconv.Atoi(name)
+		if err != nil {
+			named = append(named, query.Named(name, val))
+			continue
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("invalid parameter $%v", name)
+		}
+		for len(positional) < n {
+			positional = append(positional, nil)
+		}
+		positional[n-1] = val
+	}
+
+	for i := range positional {
+		if _, ok := p[strconv.Itoa(i+1)]; !ok {
+			return nil, fmt.Errorf("missing -p %v=value", i+1)
+		}
+	}
+
+	return append(positional, named...), nil
+}
+
 func homeDir() string {
 	if h := os.Getenv("HOME"); h != "" {
 		return h
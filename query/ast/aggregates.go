@@ -0,0 +1,146 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/saracen/kubeql/query/lexer"
+)
+
+func init() {
+	RegisterAggregate(&AggregateFunction{
+		Name: "count",
+		Signature: Signature{
+			Args:    []Type{AnyType},
+			Returns: IntegerType,
+		},
+		New: func() Aggregator { return &countAggregator{} },
+	})
+
+	RegisterAggregate(&AggregateFunction{
+		Name: "sum",
+		Signature: Signature{
+			Args:    []Type{AnyType},
+			MinArgs: 1,
+			Returns: AnyType,
+		},
+		New: func() Aggregator { return &sumAggregator{} },
+	})
+
+	RegisterAggregate(&AggregateFunction{
+		Name: "avg",
+		Signature: Signature{
+			Args:    []Type{FloatType},
+			MinArgs: 1,
+			Returns: FloatType,
+		},
+		New: func() Aggregator { return &avgAggregator{} },
+	})
+
+	RegisterAggregate(&AggregateFunction{
+		Name: "min",
+		Signature: Signature{
+			Args:    []Type{AnyType},
+			MinArgs: 1,
+			Returns: AnyType,
+		},
+		New: func() Aggregator { return &extremeAggregator{op: Operator(lexer.LessThan)} },
+	})
+
+	RegisterAggregate(&AggregateFunction{
+		Name: "max",
+		Signature: Signature{
+			Args:    []Type{AnyType},
+			MinArgs: 1,
+			Returns: AnyType,
+		},
+		New: func() Aggregator { return &extremeAggregator{op: Operator(lexer.GreaterThan)} },
+	})
+}
+
+// countAggregator counts rows, or with an argument, rows where the argument
+// isn't null.
+type countAggregator struct {
+	count int64
+}
+
+func (a *countAggregator) Step(args []interface{}) error {
+	a.count++
+	return nil
+}
+
+func (a *countAggregator) Result() (interface{}, error) {
+	return a.count, nil
+}
+
+type sumAggregator struct {
+	sum interface{}
+}
+
+func (a *sumAggregator) Step(args []interface{}) error {
+	if t := TypeOf(args[0]); !t.IsNumeric() && t != IntervalType && t != QuantityType {
+		return fmt.Errorf("type mismatch: sum() expects numbers, intervals or quantities, got %v", t)
+	}
+
+	if a.sum == nil {
+		a.sum = args[0]
+		return nil
+	}
+
+	sum, err := op(a.sum, Operator(lexer.Add), args[0])
+	if err != nil {
+		return err
+	}
+	a.sum = sum
+
+	return nil
+}
+
+func (a *sumAggregator) Result() (interface{}, error) {
+	return a.sum, nil
+}
+
+type avgAggregator struct {
+	sum   float64
+	count int64
+}
+
+func (a *avgAggregator) Step(args []interface{}) error {
+	a.sum += args[0].(float64)
+	a.count++
+	return nil
+}
+
+func (a *avgAggregator) Result() (interface{}, error) {
+	if a.count == 0 {
+		return nil, nil
+	}
+	return a.sum / float64(a.count), nil
+}
+
+// extremeAggregator keeps the value for which op holds against every other
+// value.
+type extremeAggregator struct {
+	op  Operator
+	val interface{}
+}
+
+func (a *extremeAggregator) Step(args []interface{}) error {
+	if a.val == nil {
+		a.val = args[0]
+		return nil
+	}
+
+	replace, err := op(args[0], a.op, a.val)
+	if err != nil {
+		return err
+	}
+	if replace == true {
+		a.val = args[0]
+	}
+
+	return nil
+}
+
+func (a *extremeAggregator) Result() (interface{}, error) {
+	return a.val, nil
+}
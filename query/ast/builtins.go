@@ -0,0 +1,198 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/reflect/filq"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+func init() {
+	RegisterFunction(&Function{
+		Name: "jsonpath",
+		Signature: Signature{
+			Args:    []Type{AnyType, StringType},
+			MinArgs: 2,
+			Returns: ArrayType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Compile:       compileJsonPath,
+		Program:       1,
+	})
+
+	RegisterFunction(&Function{
+		Name: "jq",
+		Signature: Signature{
+			Args:    []Type{AnyType, StringType},
+			MinArgs: 2,
+			Returns: ArrayType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Compile:       compileJQ,
+		Program:       1,
+	})
+
+	// the _value variants return a single result, or null when there's none
+	RegisterFunction(&Function{
+		Name: "jsonpath_value",
+		Signature: Signature{
+			Args:    []Type{AnyType, StringType},
+			MinArgs: 2,
+			Returns: AnyType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Compile:       compileValue("jsonpath_value", compileJsonPath),
+		Program:       1,
+	})
+
+	RegisterFunction(&Function{
+		Name: "jq_value",
+		Signature: Signature{
+			Args:    []Type{AnyType, StringType},
+			MinArgs: 2,
+			Returns: AnyType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Compile:       compileValue("jq_value", compileJQ),
+		Program:       1,
+	})
+
+	// in FROM, jsonpath and jq return a row for each result
+	RegisterSetReturningFunction(&SetReturningFunction{
+		Name: "jsonpath",
+		Signature: Signature{
+			Args:    []Type{AnyType, StringType},
+			MinArgs: 2,
+			Returns: AnyType,
+		},
+		Columns: []string{"value"},
+		Compile: compileRows(compileJsonPath),
+		Program: 1,
+	})
+
+	RegisterSetReturningFunction(&SetReturningFunction{
+		Name: "jq",
+		Signature: Signature{
+			Args:    []Type{AnyType, StringType},
+			MinArgs: 2,
+			Returns: AnyType,
+		},
+		Columns: []string{"value"},
+		Compile: compileRows(compileJQ),
+		Program: 1,
+	})
+}
+
+type compileFunc func(program string) (func(args []interface{}) (interface{}, error), error)
+
+// compileValue returns a compiler of programs that return a single result,
+// rather than an array of results. No result, or a single null result, is
+// null, and more than one result is an error.
+func compileValue(name string, compile compileFunc) compileFunc {
+	return func(program string) (func(args []interface{}) (interface{}, error), error) {
+		eval, err := compile(program)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(args []interface{}) (interface{}, error) {
+			val, err := eval(args)
+			if err != nil {
+				return nil, err
+			}
+
+			val, secret := revealSecret(val)
+			results, _ := val.([]interface{})
+			switch len(results) {
+			case 0:
+				return nil, nil
+			case 1:
+				return concealSecret(results[0], secret), nil
+			}
+			return nil, fmt.Errorf("%v() returned %v results, expected one", name, len(results))
+		}, nil
+	}
+}
+
+// compileRows returns a compiler of programs that return a row for each
+// result.
+func compileRows(compile compileFunc) func(program string) (func(args []interface{}) ([][]interface{}, error), error) {
+	return func(program string) (func(args []interface{}) ([][]interface{}, error), error) {
+		eval, err := compile(program)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(args []interface{}) ([][]interface{}, error) {
+			val, err := eval(args)
+			if err != nil {
+				return nil, err
+			}
+
+			val, secret := revealSecret(val)
+			results, _ := val.([]interface{})
+
+			rows := make([][]interface{}, len(results))
+			for i, result := range results {
+				rows[i] = []interface{}{concealSecret(result, secret)}
+			}
+			return rows, nil
+		}, nil
+	}
+}
+
+// jsonpath and jq can't see inside secrets, so they're evaluated against the
+// revealed data, and the result is a secret if the data contained one.
+
+func compileJsonPath(path string) (func(args []interface{}) (interface{}, error), error) {
+	jp := jsonpath.New(path).AllowMissingKeys(true)
+	if err := jp.Parse(path); err != nil {
+		return nil, fmt.Errorf("jsonpath error, %v", err)
+	}
+
+	return func(args []interface{}) (val interface{}, err error) {
+		data, secret := revealSecrets(args[0])
+		if secret {
+			defer func() {
+				val = concealSecret(val, true)
+			}()
+		}
+
+		fullresults, err := jp.FindResults(data)
+		if err != nil {
+			return nil, fmt.Errorf("jsonpath error, %v", err)
+		}
+
+		ret := make([]interface{}, 0)
+		for _, results := range fullresults {
+			for _, result := range results {
+				ret = append(ret, result.Interface())
+			}
+		}
+
+		return ret, nil
+	}, nil
+}
+
+// compileJQ returns a function running a jq program with filq.
+func compileJQ(path string) (func(args []interface{}) (interface{}, error), error) {
+	return func(args []interface{}) (val interface{}, err error) {
+		data, secret := revealSecrets(args[0])
+		if secret {
+			defer func() {
+				val = concealSecret(val, true)
+			}()
+		}
+
+		outs, err := filq.Run(filq.NewContext(), path, data)
+		if err != nil {
+			return nil, fmt.Errorf("jq error, %v", err)
+		}
+
+		return outs, nil
+	}, nil
+}
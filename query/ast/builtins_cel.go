@@ -0,0 +1,142 @@
+package ast
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/ext"
+	"github.com/saracen/kubeql/query/joiner"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// celEnv declares the variables of CEL programs. As in validation rules and
+// admission policies, the value a program is evaluated against is self, and
+// also object.
+var celEnv *cel.Env
+
+func init() {
+	env, err := cel.NewEnv(
+		cel.Variable("self", cel.DynType),
+		cel.Variable("object", cel.DynType),
+		ext.Strings(),
+	)
+	if err != nil {
+		panic(err)
+	}
+	celEnv = env
+
+	RegisterFunction(&Function{
+		Name: "cel",
+		Signature: Signature{
+			Args:    []Type{AnyType, StringType},
+			MinArgs: 2,
+			Returns: AnyType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Compile:       compileCEL,
+		Program:       1,
+	})
+}
+
+func compileCEL(program string) (func(args []interface{}) (interface{}, error), error) {
+	checked, issues := celEnv.Compile(program)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("cel error, %v", issues.Err())
+	}
+
+	prg, err := celEnv.Program(checked)
+	if err != nil {
+		return nil, fmt.Errorf("cel error, %v", err)
+	}
+
+	return func(args []interface{}) (val interface{}, err error) {
+		self, secret := revealSecrets(toCELValue(args[0]))
+		if secret {
+			defer func() {
+				val = concealSecret(val, true)
+			}()
+		}
+
+		out, _, err := prg.Eval(map[string]interface{}{
+			"self":   self,
+			"object": self,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cel error, %v", err)
+		}
+
+		return fromCELValue(out)
+	}, nil
+}
+
+// toCELValue converts the types CEL doesn't know to their CEL equivalents.
+func toCELValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case joiner.Tuple:
+		return map[string]interface{}(v)
+	case Duration:
+		return time.Duration(v)
+	case resource.Quantity:
+		return v.String()
+	}
+	return val
+}
+
+// fromCELValue converts the result of a CEL program to a value.
+func fromCELValue(val ref.Val) (interface{}, error) {
+	switch v := val.(type) {
+	case types.Null:
+		return nil, nil
+	case types.Bool:
+		return bool(v), nil
+	case types.Int:
+		return int64(v), nil
+	case types.Uint:
+		return int64(v), nil
+	case types.Double:
+		return float64(v), nil
+	case types.String:
+		return string(v), nil
+	case types.Bytes:
+		return string(v), nil
+	case types.Timestamp:
+		return v.Time.UTC(), nil
+	case types.Duration:
+		return Duration(v.Duration), nil
+	case *types.Err:
+		return nil, fmt.Errorf("cel error, %v", v)
+
+	case traits.Mapper:
+		obj := make(map[string]interface{})
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			elem, err := fromCELValue(v.Get(key))
+			if err != nil {
+				return nil, err
+			}
+			obj[fmt.Sprint(key.Value())] = elem
+		}
+		return obj, nil
+
+	case traits.Lister:
+		var arr []interface{}
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			elem, err := fromCELValue(it.Next())
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, elem)
+		}
+		if arr == nil {
+			arr = []interface{}{}
+		}
+		return arr, nil
+	}
+
+	return val.Value(), nil
+}
@@ -0,0 +1,68 @@
+package ast
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+)
+
+func init() {
+	encodingFunction := func(name string, eval func(s string) (interface{}, error)) {
+		RegisterFunction(&Function{
+			Name: name,
+			Signature: Signature{
+				Args:    []Type{StringType},
+				MinArgs: 1,
+				Returns: StringType,
+			},
+			Deterministic: true,
+			Eval: func(args []interface{}) (interface{}, error) {
+				val, err := eval(args[0].(string))
+				if err != nil {
+					return nil, fmt.Errorf("%v() %v", name, err)
+				}
+				return val, nil
+			},
+		})
+	}
+
+	encodingFunction("base64_encode", func(s string) (interface{}, error) {
+		return base64.StdEncoding.EncodeToString([]byte(s)), nil
+	})
+
+	// secret data is base64 encoded
+	encodingFunction("base64_decode", func(s string) (interface{}, error) {
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64, %v", err)
+		}
+		return string(data), nil
+	})
+
+	encodingFunction("sha256", func(s string) (interface{}, error) {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:]), nil
+	})
+
+	encodingFunction("hex", func(s string) (interface{}, error) {
+		return hex.EncodeToString([]byte(s)), nil
+	})
+
+	encodingFunction("gunzip", func(s string) (interface{}, error) {
+		r, err := gzip.NewReader(bytes.NewReader([]byte(s)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip data, %v", err)
+		}
+		defer r.Close()
+
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip data, %v", err)
+		}
+		return string(data), nil
+	})
+}
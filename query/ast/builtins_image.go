@@ -0,0 +1,231 @@
+package ast
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	imageFunction := func(name string, eval func(ref *imageReference) interface{}) {
+		RegisterFunction(&Function{
+			Name: name,
+			Signature: Signature{
+				Args:    []Type{StringType},
+				MinArgs: 1,
+				Returns: StringType,
+			},
+			Deterministic: true,
+			Eval: func(args []interface{}) (interface{}, error) {
+				ref, err := parseImageReference(args[0].(string))
+				if err != nil {
+					return nil, fmt.Errorf("%v() %v", name, err)
+				}
+				return eval(ref), nil
+			},
+		})
+	}
+
+	imageFunction("image_registry", func(ref *imageReference) interface{} {
+		return ref.Registry
+	})
+
+	imageFunction("image_repo", func(ref *imageReference) interface{} {
+		return ref.Repository
+	})
+
+	imageFunction("image_tag", func(ref *imageReference) interface{} {
+		if ref.Tag == "" {
+			return nil
+		}
+		return ref.Tag
+	})
+
+	imageFunction("image_digest", func(ref *imageReference) interface{} {
+		if ref.Digest == "" {
+			return nil
+		}
+		return ref.Digest
+	})
+
+	RegisterFunction(&Function{
+		Name: "semver_compare",
+		Signature: Signature{
+			Args:    []Type{StringType, StringType},
+			MinArgs: 2,
+			Returns: IntegerType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			a, ok := parseSemver(args[0].(string))
+			if !ok {
+				return nil, nil
+			}
+			b, ok := parseSemver(args[1].(string))
+			if !ok {
+				return nil, nil
+			}
+			return int64(a.compare(b)), nil
+		},
+	})
+}
+
+const (
+	defaultRegistry = "docker.io"
+	defaultTag      = "latest"
+)
+
+var (
+	imagePathComponent = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*$`)
+	imageTag           = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	imageDigest        = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+)
+
+// imageReference is a container image reference, normalized the same way as
+// docker: images without a registry are from docker.io, official images are
+// in the library repository, and images without a tag or digest are latest.
+type imageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+func parseImageReference(image string) (*imageReference, error) {
+	ref := &imageReference{}
+	name := image
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if !imageDigest.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("invalid image digest %q", image)
+		}
+	}
+
+	// a tag follows the last colon, unless it's the registry's port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+		if !imageTag.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("invalid image tag %q", image)
+		}
+	}
+
+	// the first component is a registry if it looks like a hostname
+	ref.Registry, ref.Repository = defaultRegistry, name
+	if i := strings.Index(name, "/"); i >= 0 {
+		if host := name[:i]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry, ref.Repository = host, name[i+1:]
+		}
+	}
+
+	if ref.Registry == "index.docker.io" {
+		ref.Registry = defaultRegistry
+	}
+	if ref.Registry == defaultRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+
+	for _, component := range strings.Split(ref.Repository, "/") {
+		if !imagePathComponent.MatchString(component) {
+			return nil, fmt.Errorf("invalid image repository %q", image)
+		}
+	}
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = defaultTag
+	}
+
+	return ref, nil
+}
+
+// semver is a semantic version. Versions are compared by major, minor and
+// patch, then a version with a pre-release is lower than one without.
+type semver struct {
+	version    [3]int64
+	prerelease []string
+}
+
+var semverPattern = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// parseSemver parses a version such as "1.2.3", "v1.2" or "1.0.0-rc.1". Missing
+// minor and patch versions are zero.
+func parseSemver(text string) (*semver, bool) {
+	match := semverPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nil, false
+	}
+
+	v := &semver{}
+	for i := range v.version {
+		if match[i+1] == "" {
+			continue
+		}
+		num, err := strconv.ParseInt(match[i+1], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		v.version[i] = num
+	}
+
+	if match[4] != "" {
+		v.prerelease = strings.Split(match[4], ".")
+	}
+
+	return v, true
+}
+
+// compare returns -1, 0 or 1 depending on whether v is lower than, equal to
+// or greater than other.
+func (v *semver) compare(other *semver) int {
+	for i := range v.version {
+		if c := compareInt(v.version[i], other.version[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(v.prerelease) == 0 && len(other.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		if c := comparePrerelease(v.prerelease[i], other.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareInt(int64(len(v.prerelease)), int64(len(other.prerelease)))
+}
+
+// comparePrerelease compares pre-release identifiers: numeric identifiers
+// compare numerically and are lower than alphanumeric identifiers.
+func comparePrerelease(a, b string) int {
+	an, aerr := strconv.ParseInt(a, 10, 64)
+	bn, berr := strconv.ParseInt(b, 10, 64)
+
+	switch {
+	case aerr == nil && berr == nil:
+		return compareInt(an, bn)
+	case aerr == nil:
+		return -1
+	case berr == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
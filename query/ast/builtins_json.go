@@ -0,0 +1,333 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+func init() {
+	RegisterFunction(&Function{
+		Name: "json_typeof",
+		Signature: Signature{
+			Args:    []Type{AnyType},
+			MinArgs: 1,
+			Returns: StringType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			return jsonTypeOf(args[0]), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "json_keys",
+		Signature: Signature{
+			Args:    []Type{ObjectType},
+			MinArgs: 1,
+			Returns: ArrayType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			obj, _ := toObject(args[0])
+			return jsonKeys(obj), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "json_length",
+		Signature: Signature{
+			Args:    []Type{AnyType},
+			MinArgs: 1,
+			Returns: IntegerType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			if obj, ok := toObject(args[0]); ok {
+				return int64(len(obj)), nil
+			}
+			if arr, ok := args[0].([]interface{}); ok {
+				return int64(len(arr)), nil
+			}
+			return nil, fmt.Errorf("type mismatch: json_length() expects object or array, got %v", TypeOf(args[0]))
+		},
+	})
+	RegisterFunction(&Function{
+		Name: "json_build_object",
+		Signature: Signature{
+			Args:     []Type{AnyType},
+			Variadic: true,
+			Returns:  ObjectType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Eval:          evalJSONBuildObject,
+	})
+
+	RegisterFunction(&Function{
+		Name: "json_build_array",
+		Signature: Signature{
+			Args:     []Type{AnyType},
+			Variadic: true,
+			Returns:  ArrayType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			return append([]interface{}{}, args...), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "json_set",
+		Signature: Signature{
+			Args:    []Type{AnyType, AnyType, AnyType},
+			MinArgs: 3,
+			Returns: AnyType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			if args[0] == nil || args[1] == nil {
+				return nil, nil
+			}
+
+			path, err := jsonPath("json_set", args[1])
+			if err != nil {
+				return nil, err
+			}
+			return jsonSet(args[0], path, args[2])
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "json_remove",
+		Signature: Signature{
+			Args:    []Type{AnyType, AnyType},
+			MinArgs: 2,
+			Returns: AnyType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			path, err := jsonPath("json_remove", args[1])
+			if err != nil {
+				return nil, err
+			}
+			return jsonRemove(args[0], path), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "to_json",
+		Signature: Signature{
+			Args:    []Type{AnyType},
+			MinArgs: 1,
+			Returns: StringType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			text, err := json.Marshal(args[0])
+			if err != nil {
+				return nil, fmt.Errorf("to_json() %v", err)
+			}
+			return string(text), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "parse_json",
+		Signature: Signature{
+			Args:    []Type{StringType},
+			MinArgs: 1,
+			Returns: AnyType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			val, err := parseJSON(args[0].(string))
+			if err != nil {
+				return nil, fmt.Errorf("parse_json() invalid JSON, %v", err)
+			}
+			return val, nil
+		},
+	})
+}
+
+// evalJSONBuildObject builds an object from alternating keys and values.
+func evalJSONBuildObject(args []interface{}) (interface{}, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("json_build_object() expects an even number of arguments, got %v", len(args))
+	}
+
+	obj := make(map[string]interface{}, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		if args[i] == nil {
+			return nil, fmt.Errorf("json_build_object() key must not be null")
+		}
+		obj[toText(args[i])] = args[i+1]
+	}
+
+	return obj, nil
+}
+
+// jsonPath converts a path, either a single key or an array of keys and
+// indexes, to a list of keys.
+func jsonPath(name string, val interface{}) ([]string, error) {
+	switch v := val.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		path := make([]string, len(v))
+		for i, elem := range v {
+			if elem == nil {
+				return nil, fmt.Errorf("%v() path element must not be null", name)
+			}
+			path[i] = toText(elem)
+		}
+		return path, nil
+	}
+
+	return nil, fmt.Errorf("type mismatch: %v() path expects string or array, got %v", name, TypeOf(val))
+}
+
+// jsonSet returns a copy of a value with the value at path replaced. Missing
+// object keys are created, and a negative array index counts from the end.
+// Only the objects and arrays along the path are copied, so the original
+// value, which may be a resource shared between rows, is left unchanged.
+func jsonSet(target interface{}, path []string, val interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return val, nil
+	}
+
+	if obj, ok := toObject(target); ok {
+		result := make(map[string]interface{}, len(obj)+1)
+		for k, v := range obj {
+			result[k] = v
+		}
+
+		child, err := jsonSet(obj[path[0]], path[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		result[path[0]] = child
+		return result, nil
+	}
+
+	if arr, ok := target.([]interface{}); ok {
+		idx, ok := jsonIndex(arr, path[0])
+		if !ok {
+			return nil, fmt.Errorf("json_set() array index %q out of range", path[0])
+		}
+
+		result := append([]interface{}{}, arr...)
+		child, err := jsonSet(arr[idx], path[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		result[idx] = child
+		return result, nil
+	}
+
+	if target == nil {
+		return jsonSet(map[string]interface{}{}, path, val)
+	}
+
+	return nil, fmt.Errorf("type mismatch: json_set() can't set %q of %v", path[0], TypeOf(target))
+}
+
+// jsonRemove returns a copy of a value with the value at path removed. Paths
+// that don't exist leave the value unchanged.
+func jsonRemove(target interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return target
+	}
+
+	if obj, ok := toObject(target); ok {
+		child, ok := obj[path[0]]
+		if !ok {
+			return target
+		}
+
+		result := make(map[string]interface{}, len(obj))
+		for k, v := range obj {
+			result[k] = v
+		}
+
+		if len(path) == 1 {
+			delete(result, path[0])
+		} else {
+			result[path[0]] = jsonRemove(child, path[1:])
+		}
+		return result
+	}
+
+	if arr, ok := target.([]interface{}); ok {
+		idx, ok := jsonIndex(arr, path[0])
+		if !ok {
+			return target
+		}
+
+		if len(path) == 1 {
+			return append(append([]interface{}{}, arr[:idx]...), arr[idx+1:]...)
+		}
+
+		result := append([]interface{}{}, arr...)
+		result[idx] = jsonRemove(arr[idx], path[1:])
+		return result
+	}
+
+	return target
+}
+
+// jsonIndex returns the array index of a path element, counting from the end
+// if it's negative.
+func jsonIndex(arr []interface{}, key string) (int, bool) {
+	idx, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, false
+	}
+	if idx < 0 {
+		idx += len(arr)
+	}
+	return idx, idx >= 0 && idx < len(arr)
+}
+
+// parseJSON decodes JSON with integers as int64 and other numbers as
+// float64, the same as resources.
+func parseJSON(text string) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewBufferString(text))
+	dec.UseNumber()
+
+	var val interface{}
+	if err := dec.Decode(&val); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+
+	return normalizeJSON(val), nil
+}
+
+func normalizeJSON(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = normalizeJSON(elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = normalizeJSON(elem)
+		}
+	}
+
+	return val
+}
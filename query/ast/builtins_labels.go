@@ -0,0 +1,89 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func init() {
+	RegisterFunction(&Function{
+		Name: "matches_selector",
+		Signature: Signature{
+			Args:    []Type{ObjectType, AnyType},
+			MinArgs: 2,
+			Returns: BooleanType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Eval:          evalMatchesSelector,
+	})
+}
+
+// evalMatchesSelector reports whether a set of labels matches a selector.
+// Resources without labels have an empty set of labels, and a null selector
+// is null.
+func evalMatchesSelector(args []interface{}) (interface{}, error) {
+	if args[1] == nil {
+		return nil, nil
+	}
+
+	set := labels.Set{}
+	if args[0] != nil {
+		for key, val := range args[0].(map[string]interface{}) {
+			set[key] = toText(val)
+		}
+	}
+
+	selector, err := toSelector(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("matches_selector() %v", err)
+	}
+
+	return selector.Matches(set), nil
+}
+
+// toSelector converts a selector string, such as "app=web,tier in (fe)", a
+// LabelSelector with matchLabels and matchExpressions, as used by
+// deployments, or a map of labels, as used by services, to a selector.
+func toSelector(val interface{}) (labels.Selector, error) {
+	switch v := val.(type) {
+	case string:
+		selector, err := labels.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q, %v", v, err)
+		}
+		return selector, nil
+
+	case map[string]interface{}:
+		_, hasLabels := v["matchLabels"]
+		_, hasExpressions := v["matchExpressions"]
+		if !hasLabels && !hasExpressions {
+			set := labels.Set{}
+			for key, val := range v {
+				set[key] = toText(val)
+			}
+			return labels.SelectorFromSet(set), nil
+		}
+
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		var ls metav1.LabelSelector
+		if err := json.Unmarshal(data, &ls); err != nil {
+			return nil, fmt.Errorf("invalid label selector, %v", err)
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(&ls)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector, %v", err)
+		}
+		return selector, nil
+	}
+
+	return nil, fmt.Errorf("type mismatch: expected selector string or object, got %v", TypeOf(val))
+}
@@ -0,0 +1,75 @@
+package ast
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func init() {
+	RegisterFunction(&Function{
+		Name: "quantity",
+		Signature: Signature{
+			Args:    []Type{QuantityType},
+			MinArgs: 1,
+			Returns: QuantityType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			return args[0], nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "to_cores",
+		Signature: Signature{
+			Args:    []Type{QuantityType},
+			MinArgs: 1,
+			Returns: FloatType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			return quantityFloat(args[0].(resource.Quantity)), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "to_millicores",
+		Signature: Signature{
+			Args:    []Type{QuantityType},
+			MinArgs: 1,
+			Returns: IntegerType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			q := args[0].(resource.Quantity)
+			return q.MilliValue(), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "to_bytes",
+		Signature: Signature{
+			Args:    []Type{QuantityType},
+			MinArgs: 1,
+			Returns: IntegerType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			q := args[0].(resource.Quantity)
+			return q.Value(), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "format_bytes",
+		Signature: Signature{
+			Args:    []Type{QuantityType},
+			MinArgs: 1,
+			Returns: QuantityType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			q := args[0].(resource.Quantity)
+			return *resource.NewQuantity(q.Value(), resource.BinarySI), nil
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package ast
+
+func init() {
+	RegisterSetReturningFunction(&SetReturningFunction{
+		Name: "unnest",
+		Signature: Signature{
+			Args:    []Type{ArrayType},
+			MinArgs: 1,
+			Returns: AnyType,
+		},
+		Columns: []string{"value"},
+		Eval: func(args []interface{}) ([][]interface{}, error) {
+			elems := args[0].([]interface{})
+
+			rows := make([][]interface{}, len(elems))
+			for i, elem := range elems {
+				rows[i] = []interface{}{elem}
+			}
+			return rows, nil
+		},
+	})
+
+	// json_each returns the entries of an object sorted by key, so that
+	// results are repeatable
+	RegisterSetReturningFunction(&SetReturningFunction{
+		Name: "json_each",
+		Signature: Signature{
+			Args:    []Type{ObjectType},
+			MinArgs: 1,
+			Returns: ObjectType,
+		},
+		Columns: []string{"key", "value"},
+		Eval: func(args []interface{}) ([][]interface{}, error) {
+			obj, _ := toObject(args[0])
+
+			keys := jsonKeys(obj)
+			rows := make([][]interface{}, len(keys))
+			for i, key := range keys {
+				rows[i] = []interface{}{key, obj[key.(string)]}
+			}
+			return rows, nil
+		},
+	})
+}
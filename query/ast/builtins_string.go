@@ -0,0 +1,408 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func init() {
+	stringFunction := func(name string, args []Type, minArgs int, returns Type, eval func(args []interface{}) (interface{}, error)) {
+		RegisterFunction(&Function{
+			Name: name,
+			Signature: Signature{
+				Args:    args,
+				MinArgs: minArgs,
+				Returns: returns,
+			},
+			Deterministic: true,
+			Eval:          eval,
+		})
+	}
+
+	stringFunction("lower", []Type{StringType}, 1, StringType, func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(args[0].(string)), nil
+	})
+
+	stringFunction("upper", []Type{StringType}, 1, StringType, func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(args[0].(string)), nil
+	})
+
+	stringFunction("length", []Type{StringType}, 1, IntegerType, func(args []interface{}) (interface{}, error) {
+		return int64(utf8.RuneCountInString(args[0].(string))), nil
+	})
+
+	stringFunction("substr", []Type{StringType, IntegerType, IntegerType}, 2, StringType, evalSubstr)
+
+	stringFunction("trim", []Type{StringType, StringType}, 1, StringType, func(args []interface{}) (interface{}, error) {
+		if len(args) > 1 {
+			return strings.Trim(args[0].(string), args[1].(string)), nil
+		}
+		return strings.TrimSpace(args[0].(string)), nil
+	})
+
+	stringFunction("split_part", []Type{StringType, StringType, IntegerType}, 3, StringType, evalSplitPart)
+
+	stringFunction("replace", []Type{StringType, StringType, StringType}, 3, StringType, func(args []interface{}) (interface{}, error) {
+		return strings.Replace(args[0].(string), args[1].(string), args[2].(string), -1), nil
+	})
+
+	stringFunction("starts_with", []Type{StringType, StringType}, 2, BooleanType, func(args []interface{}) (interface{}, error) {
+		return strings.HasPrefix(args[0].(string), args[1].(string)), nil
+	})
+
+	stringFunction("ends_with", []Type{StringType, StringType}, 2, BooleanType, func(args []interface{}) (interface{}, error) {
+		return strings.HasSuffix(args[0].(string), args[1].(string)), nil
+	})
+
+	stringFunction("contains", []Type{StringType, StringType}, 2, BooleanType, func(args []interface{}) (interface{}, error) {
+		return strings.Contains(args[0].(string), args[1].(string)), nil
+	})
+
+	stringFunction("regexp_replace", []Type{StringType, StringType, StringType, StringType}, 3, StringType, evalRegexpReplace)
+	stringFunction("regexp_match", []Type{StringType, StringType, StringType}, 2, ArrayType, evalRegexpMatch)
+
+	RegisterFunction(&Function{
+		Name: "concat",
+		Signature: Signature{
+			Args:     []Type{AnyType},
+			Variadic: true,
+			Returns:  StringType,
+		},
+		CallOnNull:    true,
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			var buf bytes.Buffer
+			for _, arg := range args {
+				// nulls are ignored
+				if arg != nil {
+					buf.WriteString(toText(arg))
+				}
+			}
+			return buf.String(), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "format",
+		Signature: Signature{
+			Args:     []Type{StringType, AnyType},
+			MinArgs:  1,
+			Variadic: true,
+			Returns:  StringType,
+		},
+		Deterministic: true,
+		Compile:       compileFormat,
+		Program:       0,
+	})
+}
+
+// formatVerbs are the verbs format() accepts, and the types of the arguments
+// they format.
+var formatVerbs = map[rune]string{
+	'v': "any value",
+	's': "a string", 'q': "a string",
+	'd': "an integer",
+	'x': "an integer or string", 'X': "an integer or string",
+	'e': "a number", 'E': "a number", 'f': "a number", 'F': "a number", 'g': "a number", 'G': "a number",
+	't': "a boolean",
+}
+
+// compileFormat parses a printf-style format string, returning a function
+// that formats its arguments once they're checked against the verbs.
+func compileFormat(format string) (func(args []interface{}) (interface{}, error), error) {
+	var verbs []rune
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+
+		// flags, width and precision
+		j := i + 1
+		for j < len(format) && strings.IndexByte("+-# 0123456789.", format[j]) >= 0 {
+			j++
+		}
+		if j == len(format) {
+			return nil, fmt.Errorf("format() %q is missing a verb", format[i:])
+		}
+
+		verb, size := utf8.DecodeRuneInString(format[j:])
+		if verb == '%' && j == i+1 {
+			i = j
+			continue
+		}
+		if _, ok := formatVerbs[verb]; !ok {
+			return nil, fmt.Errorf("format() unsupported verb %q", format[i:j+size])
+		}
+
+		verbs = append(verbs, verb)
+		i = j + size - 1
+	}
+
+	return func(args []interface{}) (val interface{}, err error) {
+		// secrets within objects and arrays would otherwise be formatted
+		// by their value
+		revealed, secret := revealSecrets(args[1:])
+		if secret {
+			defer func() {
+				val = concealSecret(val, true)
+			}()
+		}
+
+		values := revealed.([]interface{})
+		if len(values) < len(verbs) {
+			return nil, &argError{index: 0, err: fmt.Errorf("format() expects %v arguments, got %v", len(verbs), len(values))}
+		}
+		if len(values) > len(verbs) {
+			return nil, &argError{index: len(verbs) + 1, err: fmt.Errorf("format() expects %v arguments, got %v", len(verbs), len(values))}
+		}
+
+		for i, verb := range verbs {
+			converted, ok := formatValue(verb, values[i])
+			if !ok {
+				return nil, &argError{index: i + 1, err: fmt.Errorf("format() %%%c expects %v, got %v", verb, formatVerbs[verb], TypeOf(values[i]))}
+			}
+			values[i] = converted
+		}
+
+		return fmt.Sprintf(format, values...), nil
+	}, nil
+}
+
+// formatValue returns the value a verb formats for an argument, and whether
+// the verb can format it. Timestamps, intervals and quantities are formatted
+// as text, and integers can be formatted as floats.
+func formatValue(verb rune, val interface{}) (interface{}, bool) {
+	switch verb {
+	case 'v':
+		return val, true
+	case 's', 'q':
+		switch val.(type) {
+		case string:
+			return val, true
+		case time.Time, Duration, resource.Quantity:
+			return toText(val), true
+		}
+	case 'd':
+		_, ok := val.(int64)
+		return val, ok
+	case 'x', 'X':
+		switch val.(type) {
+		case int64, string:
+			return val, true
+		}
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		if num, ok := toNumber(val); ok {
+			return toFloat(num), true
+		}
+	case 't':
+		_, ok := val.(bool)
+		return val, ok
+	}
+
+	return nil, false
+}
+
+// toText converts a value to its text representation: strings are
+// unchanged, timestamps are RFC3339, intervals are human-readable,
+// quantities are in canonical form and everything else is formatted as JSON.
+func toText(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case Duration:
+		return v.String()
+	case resource.Quantity:
+		return v.String()
+	}
+
+	text, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprint(val)
+	}
+	return string(text)
+}
+
+// evalSubstr returns count characters of a string, starting from a 1-based
+// position. As with SQL, a start before the first character still counts
+// towards count.
+func evalSubstr(args []interface{}) (interface{}, error) {
+	str := []rune(args[0].(string))
+	start := args[1].(int64) - 1
+	end := int64(len(str))
+
+	if len(args) > 2 {
+		count := args[2].(int64)
+		if count < 0 {
+			return nil, fmt.Errorf("substr() negative substring length not allowed")
+		}
+		if start+count < end {
+			end = start + count
+		}
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	if start >= end {
+		return "", nil
+	}
+
+	return string(str[start:end]), nil
+}
+
+// evalSplitPart returns the nth field of a string split by a delimiter.
+// Negative positions count from the end.
+func evalSplitPart(args []interface{}) (interface{}, error) {
+	str, delim, n := args[0].(string), args[1].(string), args[2].(int64)
+	if n == 0 {
+		return nil, fmt.Errorf("split_part() field position must not be zero")
+	}
+
+	parts := []string{str}
+	if delim != "" {
+		parts = strings.Split(str, delim)
+	}
+
+	if n < 0 {
+		n += int64(len(parts)) + 1
+	}
+	if n < 1 || n > int64(len(parts)) {
+		return "", nil
+	}
+
+	return parts[n-1], nil
+}
+
+var regexpCache = struct {
+	sync.Mutex
+	regexps map[string]*regexp.Regexp
+}{regexps: make(map[string]*regexp.Regexp)}
+
+// compileRegexp compiles a pattern with flags ("i" for case-insensitive
+// matching), caching recently compiled patterns so that they're not compiled
+// for every row. It's used by both the regular expression operators and
+// functions.
+func compileRegexp(pattern, flags string) (*regexp.Regexp, error) {
+	if strings.Contains(flags, "i") {
+		pattern = "(?i)" + pattern
+	}
+
+	regexpCache.Lock()
+	defer regexpCache.Unlock()
+
+	if re, ok := regexpCache.regexps[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, patternError(err)
+	}
+
+	if len(regexpCache.regexps) >= 256 {
+		regexpCache.regexps = make(map[string]*regexp.Regexp)
+	}
+	regexpCache.regexps[pattern] = re
+
+	return re, nil
+}
+
+// patternError describes why a pattern is invalid without quoting it, as the
+// pattern may be derived from a secret.
+func patternError(err error) error {
+	if syntaxErr, ok := err.(*syntax.Error); ok {
+		return fmt.Errorf("invalid pattern, %v", syntaxErr.Code)
+	}
+	return errors.New("invalid pattern")
+}
+
+// regexpFlags returns the flags argument at idx, if any, ensuring it only
+// contains allowed flags.
+func regexpFlags(name string, args []interface{}, idx int, allowed string) (string, error) {
+	if len(args) <= idx {
+		return "", nil
+	}
+
+	flags := args[idx].(string)
+	if strings.Trim(flags, allowed) != "" {
+		return "", fmt.Errorf("%v() invalid flags %q", name, flags)
+	}
+	return flags, nil
+}
+
+// evalRegexpReplace replaces the first match of a pattern, or every match
+// with the "g" flag. The replacement can refer to capture groups as $1.
+func evalRegexpReplace(args []interface{}) (interface{}, error) {
+	str, replacement := args[0].(string), args[2].(string)
+
+	flags, err := regexpFlags("regexp_replace", args, 3, "gi")
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := compileRegexp(args[1].(string), flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(flags, "g") {
+		return re.ReplaceAllString(str, replacement), nil
+	}
+
+	match := re.FindStringSubmatchIndex(str)
+	if match == nil {
+		return str, nil
+	}
+
+	var dst []byte
+	dst = re.ExpandString(dst, replacement, str, match)
+
+	return str[:match[0]] + string(dst) + str[match[1]:], nil
+}
+
+// evalRegexpMatch returns the capture groups of the first match of a pattern,
+// or the whole match if the pattern has no groups. No match is null.
+func evalRegexpMatch(args []interface{}) (interface{}, error) {
+	flags, err := regexpFlags("regexp_match", args, 2, "i")
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := compileRegexp(args[1].(string), flags)
+	if err != nil {
+		return nil, err
+	}
+
+	match := re.FindStringSubmatchIndex(args[0].(string))
+	if match == nil {
+		return nil, nil
+	}
+
+	str := args[0].(string)
+	if len(match) == 2 {
+		return []interface{}{str[match[0]:match[1]]}, nil
+	}
+
+	groups := make([]interface{}, 0, len(match)/2-1)
+	for i := 2; i < len(match); i += 2 {
+		if match[i] < 0 {
+			groups = append(groups, nil)
+			continue
+		}
+		groups = append(groups, str[match[i]:match[i+1]])
+	}
+
+	return groups, nil
+}
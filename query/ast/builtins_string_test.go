@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	ts, _ := time.Parse(time.RFC3339, "2018-01-01T00:00:00Z")
+	format := func(args ...Expr) *Call {
+		return &Call{Name: "format", Func: LookupFunction("format"), Args: args, Offset: 7}
+	}
+	str := func(val string, offset int) Expr {
+		return &String{Val: val, Offset: offset}
+	}
+
+	tests := []struct {
+		expr     *Call
+		expected interface{}
+		err      string
+	}{
+		{expr: format(str("%s-%d", 14), str("a", 22), &Integer{Val: 1}), expected: "a-1"},
+		{expr: format(str("%5.1f%%", 14), &Integer{Val: 2}), expected: "  2.0%"},
+		{expr: format(str("%s", 14), &Timestamp{Val: ts}), expected: "2018-01-01T00:00:00Z"},
+		{expr: format(str("%x %v %t", 14), str("hi", 20), &Integer{Val: 1}, &Boolean{Val: true}), expected: "6869 1 true"},
+		{expr: format(str("no verbs", 14)), expected: "no verbs"},
+
+		// errors are reported at the argument they're about, when its
+		// position is known
+		{expr: format(str("%d", 14), str("x", 20)), err: "format() %d expects an integer, got string (offset: 20)"},
+		{expr: format(str("%s", 14)), err: "format() expects 1 arguments, got 0 (offset: 14)"},
+		{expr: format(str("%s", 14), str("a", 20), str("b", 25)), err: "format() expects 1 arguments, got 2 (offset: 25)"},
+		{expr: format(str("%t", 14), &Integer{Val: 1}), err: "format() %t expects a boolean, got integer (offset: 7)"},
+		{expr: format(str("%z", 14), &Integer{Val: 1}), err: `format() unsupported verb "%z"`},
+		{expr: format(str("50%", 14)), err: `format() "%" is missing a verb`},
+	}
+
+	for _, test := range tests {
+		format := test.expr.Args[0].(*String).Val
+
+		val, err := test.expr.Eval(nil)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: got error %v, expected %q", format, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: %v", format, err)
+			continue
+		}
+		if val != test.expected {
+			t.Errorf("%q: got %#v, expected %#v", format, val, test.expected)
+		}
+	}
+}
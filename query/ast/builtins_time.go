@@ -0,0 +1,166 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterFunction(&Function{
+		Name: "timestamp",
+		Signature: Signature{
+			Args:    []Type{TimestampType},
+			MinArgs: 1,
+			Returns: TimestampType,
+		},
+		Deterministic: true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			return args[0], nil
+		},
+	})
+
+	// like SQL's now(), now() is the time the execution of the query started,
+	// so it's the same for every call and every row of an execution.
+	RegisterFunction(&Function{
+		Name: "now",
+		Signature: Signature{
+			Returns: TimestampType,
+		},
+		Stable: true,
+		Now:    true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			return args[0], nil
+		},
+	})
+
+	// age(ts) is the interval since ts, as of now().
+	RegisterFunction(&Function{
+		Name: "age",
+		Signature: Signature{
+			Args:    []Type{TimestampType, TimestampType},
+			MinArgs: 1,
+			Returns: IntervalType,
+		},
+		Stable: true,
+		Now:    true,
+		Eval: func(args []interface{}) (interface{}, error) {
+			if len(args) > 2 {
+				return Duration(args[0].(time.Time).Sub(args[1].(time.Time))), nil
+			}
+			return Duration(args[1].(time.Time).Sub(args[0].(time.Time))), nil
+		},
+	})
+
+	RegisterFunction(&Function{
+		Name: "date_trunc",
+		Signature: Signature{
+			Args:    []Type{StringType, TimestampType},
+			MinArgs: 2,
+			Returns: TimestampType,
+		},
+		Deterministic: true,
+		Eval:          evalDateTrunc,
+	})
+
+	RegisterFunction(&Function{
+		Name: "extract",
+		Signature: Signature{
+			Args:    []Type{StringType, AnyType},
+			MinArgs: 2,
+			Returns: AnyType,
+		},
+		Deterministic: true,
+		Eval:          evalExtract,
+	})
+}
+
+// evalDateTrunc truncates a timestamp to the start of a second, minute, hour,
+// day, week (starting on Monday), month or year.
+func evalDateTrunc(args []interface{}) (interface{}, error) {
+	t := args[1].(time.Time)
+
+	switch unit := strings.ToLower(args[0].(string)); unit {
+	case "second":
+		return t.Truncate(time.Second), nil
+	case "minute":
+		return t.Truncate(time.Minute), nil
+	case "hour":
+		return t.Truncate(time.Hour), nil
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+	case "week":
+		days := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, time.UTC), nil
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	case "year":
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	return nil, fmt.Errorf("date_trunc() unknown unit %q", args[0])
+}
+
+// evalExtract returns a field of a timestamp or interval. Epoch is the number
+// of seconds since 1970 for a timestamp, and the total number of seconds for
+// an interval.
+func evalExtract(args []interface{}) (interface{}, error) {
+	field := strings.ToLower(args[0].(string))
+
+	if d, ok := args[1].(Duration); ok {
+		switch field {
+		case "epoch":
+			return time.Duration(d).Seconds(), nil
+		case "day":
+			return int64(time.Duration(d) / (24 * time.Hour)), nil
+		case "hour":
+			return int64(time.Duration(d) % (24 * time.Hour) / time.Hour), nil
+		case "minute":
+			return int64(time.Duration(d) % time.Hour / time.Minute), nil
+		case "second":
+			return (time.Duration(d) % time.Minute).Seconds(), nil
+		}
+		return nil, fmt.Errorf("extract() unknown interval field %q", args[0])
+	}
+
+	t, err := toTimestamp(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("extract() %v", err)
+	}
+
+	switch field {
+	case "epoch":
+		return float64(t.UnixNano()) / float64(time.Second), nil
+	case "year":
+		return int64(t.Year()), nil
+	case "month":
+		return int64(t.Month()), nil
+	case "day":
+		return int64(t.Day()), nil
+	case "hour":
+		return int64(t.Hour()), nil
+	case "minute":
+		return int64(t.Minute()), nil
+	case "second":
+		return float64(t.Second()) + float64(t.Nanosecond())/float64(time.Second), nil
+	case "dow":
+		return int64(t.Weekday()), nil
+	case "doy":
+		return int64(t.YearDay()), nil
+	}
+
+	return nil, fmt.Errorf("extract() unknown timestamp field %q", args[0])
+}
+
+// NowKey is the key, in the data of an execution of a query, of the time the
+// execution started. It can't be mistaken for a reference or a parameter.
+const NowKey = "now()"
+
+// executionTime returns the time the execution of a query started, or the
+// current time when an expression is evaluated outside of an execution.
+func executionTime(data map[string]interface{}) time.Time {
+	if t, ok := data[NowKey].(time.Time); ok {
+		return t
+	}
+	return time.Now().UTC()
+}
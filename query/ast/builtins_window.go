@@ -0,0 +1,99 @@
+package ast
+
+func init() {
+	RegisterWindowFunction(&WindowFunction{
+		Name:      "row_number",
+		Signature: Signature{Returns: IntegerType},
+		Eval: func(p *WindowPartition, row int) (interface{}, error) {
+			return int64(row + 1), nil
+		},
+	})
+
+	// rank numbers rows by their position in the partition, with the same
+	// rank for peers, leaving gaps after them
+	RegisterWindowFunction(&WindowFunction{
+		Name:      "rank",
+		Signature: Signature{Returns: IntegerType},
+		Eval: func(p *WindowPartition, row int) (interface{}, error) {
+			first := row
+			for first > 0 && p.Peers[first-1] == p.Peers[row] {
+				first--
+			}
+			return int64(first + 1), nil
+		},
+	})
+
+	// dense_rank numbers peer groups without gaps
+	RegisterWindowFunction(&WindowFunction{
+		Name:      "dense_rank",
+		Signature: Signature{Returns: IntegerType},
+		Eval: func(p *WindowPartition, row int) (interface{}, error) {
+			return int64(p.Peers[row] + 1), nil
+		},
+	})
+
+	RegisterWindowFunction(&WindowFunction{
+		Name: "lag",
+		Signature: Signature{
+			Args:    []Type{AnyType, IntegerType, AnyType},
+			MinArgs: 1,
+			Returns: AnyType,
+		},
+		Eval: offsetValue(-1),
+	})
+
+	RegisterWindowFunction(&WindowFunction{
+		Name: "lead",
+		Signature: Signature{
+			Args:    []Type{AnyType, IntegerType, AnyType},
+			MinArgs: 1,
+			Returns: AnyType,
+		},
+		Eval: offsetValue(1),
+	})
+
+	RegisterWindowFunction(&WindowFunction{
+		Name: "first_value",
+		Signature: Signature{
+			Args:    []Type{AnyType},
+			MinArgs: 1,
+			Returns: AnyType,
+		},
+		Eval: func(p *WindowPartition, row int) (interface{}, error) {
+			frame := p.Frames[row]
+			if frame[0] == frame[1] {
+				return nil, nil
+			}
+			return p.Args[frame[0]][0], nil
+		},
+	})
+}
+
+// offsetValue returns the value of the row an offset, 1 by default, before or
+// after a row, in the given direction. Beyond the partition, it's the default
+// argument, or null.
+func offsetValue(direction int) func(p *WindowPartition, row int) (interface{}, error) {
+	return func(p *WindowPartition, row int) (interface{}, error) {
+		args := p.Args[row]
+
+		offset := int64(1)
+		if len(args) > 1 {
+			if args[1] == nil {
+				return nil, nil
+			}
+			revealed, _ := revealSecret(args[1])
+			num, _ := toNumber(revealed)
+			offset = num.(int64)
+		}
+
+		i := row + direction*int(offset)
+		if i < 0 || i >= len(p.Args) {
+			if len(args) > 2 {
+				return args[2], nil
+			}
+			return nil, nil
+		}
+
+		return p.Args[i][0], nil
+	}
+}
@@ -0,0 +1,96 @@
+package ast
+
+import (
+	"github.com/saracen/kubeql/query/lexer"
+)
+
+// Check performs semantic analysis of an expression tree, reporting operators
+// whose operand types are known to be incompatible before any data is
+// evaluated.
+func Check(walker ExprWalker) (err error) {
+	Inspect(walker, func(expr Expr) bool {
+		if err != nil {
+			return false
+		}
+
+		switch expr := expr.(type) {
+		case *BinaryExpr:
+			lhs, rhs := StaticType(expr.LHS), StaticType(expr.RHS)
+			if _, ok := operatorType(expr.Op, lhs, rhs); !ok {
+				err = &EvalError{Offset: expr.Offset, Msg: typeMismatch(expr.Op, lhs, rhs).Error()}
+			}
+
+		case *UnaryExpr:
+			t := StaticType(expr.Expr)
+			if _, ok := unaryOperatorType(expr.Op, t); !ok {
+				err = &EvalError{Offset: expr.Offset, Msg: unaryTypeMismatch(expr.Op, t).Error()}
+			}
+
+		case *InExpr:
+			t := StaticType(expr.Expr)
+			for _, item := range expr.List {
+				if _, ok := operatorType(Operator(lexer.Equal), t, StaticType(item)); !ok {
+					err = &EvalError{Offset: expr.Offset, Msg: typeMismatch(Operator(lexer.In), t, StaticType(item)).Error()}
+					break
+				}
+			}
+
+		case *BetweenExpr:
+			t, low, high := StaticType(expr.Expr), StaticType(expr.Low), StaticType(expr.High)
+			if _, ok := operatorType(Operator(lexer.GreaterThanEqual), t, low); !ok {
+				err = &EvalError{Offset: expr.Offset, Msg: typeMismatch(Operator(lexer.Between), t, low).Error()}
+			} else if _, ok := operatorType(Operator(lexer.LessThanEqual), t, high); !ok {
+				err = &EvalError{Offset: expr.Offset, Msg: typeMismatch(Operator(lexer.Between), t, high).Error()}
+			}
+
+		case *CaseExpr:
+			if expr.Operand == nil {
+				break
+			}
+			t := StaticType(expr.Operand)
+			for _, when := range expr.Whens {
+				if _, ok := operatorType(Operator(lexer.Equal), t, StaticType(when.Condition)); !ok {
+					err = &EvalError{Offset: expr.Offset, Msg: typeMismatch(Operator(lexer.Equal), t, StaticType(when.Condition)).Error()}
+					break
+				}
+			}
+
+		case *Call:
+			err = checkArgTypes(expr.Func.Name, &expr.Func.Signature, expr.Args, expr.Offset)
+
+		case *AggregateCall:
+			err = checkArgTypes(expr.Func.Name, &expr.Func.Signature, expr.Args, expr.Offset)
+
+		case *WindowCall:
+			if expr.Func != nil {
+				err = checkArgTypes(expr.Func.Name, &expr.Func.Signature, expr.Args, expr.Offset)
+			} else {
+				err = checkArgTypes(expr.Aggregate.Name, &expr.Aggregate.Signature, expr.Args, expr.Offset)
+			}
+
+		case *SetReturningCall:
+			err = checkArgTypes(expr.Func.Name, &expr.Func.Signature, expr.Args, expr.Offset)
+
+		case *MatchExpr:
+			t, pattern := StaticType(expr.Expr), StaticType(expr.Pattern)
+			if !isType(t, NullType, StringType) || !isType(pattern, NullType, StringType) {
+				err = &EvalError{Offset: expr.Offset, Msg: typeMismatch(expr.Op, t, pattern).Error()}
+			}
+		}
+
+		return true
+	})
+
+	return err
+}
+
+func checkArgTypes(name string, sig *Signature, args []Expr, offset int) error {
+	for i, arg := range args {
+		declared, actual := sig.argType(i), StaticType(arg)
+		if !argTypeMatches(declared, actual) {
+			return &EvalError{Offset: offset, Msg: argTypeMismatch(name, i, declared, actual).Error()}
+		}
+	}
+
+	return nil
+}
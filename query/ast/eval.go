@@ -1,15 +1,15 @@
 package ast
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"reflect"
-	"strconv"
+	"regexp"
 
 	"github.com/saracen/kubeql/query/joiner"
 	"github.com/saracen/kubeql/query/lexer"
-
-	"github.com/reflect/filq"
-	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func EvalIsEmpty(expr Expr, data map[string]interface{}) (bool, error) {
@@ -29,6 +29,7 @@ func checkIfEmpty(data interface{}) (empty bool) {
 		}
 	}()
 
+	data, _ = revealSecret(data)
 	if data == nil {
 		return true
 	}
@@ -49,11 +50,37 @@ func (expr *ParenExpr) Eval(data map[string]interface{}) (interface{}, error) {
 	}
 
 	if expr.PathExpr != nil {
-		return matchPathExpression(evaled, expr.PathExpr.Fields)
+		return matchPathExpression(evaled, expr.PathExpr.Steps, data)
 	}
 	return evaled, nil
 }
 
+func (expr *ObjectLiteral) Eval(data map[string]interface{}) (interface{}, error) {
+	obj := make(map[string]interface{}, len(expr.Keys))
+	for i, key := range expr.Keys {
+		val, err := expr.Values[i].Eval(data)
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = val
+	}
+
+	return obj, nil
+}
+
+func (expr *ArrayLiteral) Eval(data map[string]interface{}) (interface{}, error) {
+	arr := make([]interface{}, len(expr.Elements))
+	for i, elem := range expr.Elements {
+		val, err := elem.Eval(data)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = val
+	}
+
+	return arr, nil
+}
+
 func (expr *Integer) Eval(data map[string]interface{}) (interface{}, error) {
 	return expr.Val, nil
 }
@@ -66,18 +93,39 @@ func (expr *String) Eval(data map[string]interface{}) (interface{}, error) {
 	return expr.Val, nil
 }
 
+func (expr *Interval) Eval(data map[string]interface{}) (interface{}, error) {
+	return expr.Val, nil
+}
+
+func (expr *Timestamp) Eval(data map[string]interface{}) (interface{}, error) {
+	return expr.Val, nil
+}
+
 func (expr *Boolean) Eval(data map[string]interface{}) (interface{}, error) {
 	return expr.Val, nil
 }
 
 func (expr *Reference) Eval(data map[string]interface{}) (interface{}, error) {
-	path := []string{expr.Name}
+	path := []*PathStep{{Key: expr.Name}}
 
 	if expr.PathExpr != nil {
-		path = append(path, expr.PathExpr.Fields...)
+		path = append(path, expr.PathExpr.Steps...)
 	}
 
-	return matchPathExpression(data, path)
+	return matchPathExpression(data, path, data)
+}
+
+func (expr *Parameter) Eval(data map[string]interface{}) (interface{}, error) {
+	val, ok := data[expr.Name]
+	if !ok {
+		return nil, &EvalError{Offset: expr.Offset, Msg: fmt.Sprintf("no value for parameter %v", expr.Name)}
+	}
+
+	if expr.PathExpr != nil {
+		return matchPathExpression(val, expr.PathExpr.Steps, data)
+	}
+
+	return val, nil
 }
 
 func (expr *BinaryExpr) Eval(data map[string]interface{}) (val interface{}, err error) {
@@ -91,224 +139,643 @@ func (expr *BinaryExpr) Eval(data map[string]interface{}) (val interface{}, err
 		return nil, err
 	}
 
-	return op(lhs, expr.Op, rhs), nil
+	val, err = op(lhs, expr.Op, rhs)
+	if err != nil {
+		if expr.Permissive {
+			return nil, nil
+		}
+		return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
+	}
+
+	return val, nil
 }
 
-func (expr *JsonPath) Eval(data map[string]interface{}) (interface{}, error) {
+func (expr *UnaryExpr) Eval(data map[string]interface{}) (interface{}, error) {
 	evaled, err := expr.Expr.Eval(data)
 	if err != nil {
 		return nil, err
 	}
 
-	jp := jsonpath.New(expr.Path).AllowMissingKeys(true)
-	if err = jp.Parse(expr.Path); err != nil {
-		return nil, fmt.Errorf("jsonpath error, %v", err)
+	val, err := unaryOp(expr.Op, evaled)
+	if err != nil {
+		if expr.Permissive {
+			return nil, nil
+		}
+		return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
 	}
 
-	fullresults, err := jp.FindResults(evaled)
-	if err != nil {
-		return nil, fmt.Errorf("jsonpath error, %v", err)
+	return val, nil
+}
+
+func (expr *InExpr) Eval(data map[string]interface{}) (val interface{}, err error) {
+	evaled, err := expr.Expr.Eval(data)
+	if err != nil || evaled == nil {
+		return nil, err
 	}
 
-	ret := make([]interface{}, 0)
-	for _, results := range fullresults {
-		for _, result := range results {
-			ret = append(ret, result.Interface())
+	// the result is secret if the expression or a list value is
+	_, secret := revealSecret(evaled)
+	defer func() {
+		val = concealSecret(val, secret)
+	}()
+
+	null := false
+	for _, item := range expr.List {
+		val, err := item.Eval(data)
+		if err != nil {
+			return nil, err
+		}
+
+		equal, err := op(evaled, Operator(lexer.Equal), val)
+		if err != nil {
+			if expr.Permissive {
+				return nil, nil
+			}
+			return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
+		}
+
+		var derived bool
+		equal, derived = revealSecret(equal)
+		secret = secret || derived
+
+		switch equal {
+		case true:
+			return !expr.Not, nil
+		case nil:
+			null = true
 		}
 	}
 
-	if expr.PathExpr != nil {
-		return matchPathExpression(ret, expr.PathExpr.Fields)
+	// as with a chain of ORs, no match with a null in the list is null
+	if null {
+		return nil, nil
+	}
+
+	return expr.Not, nil
+}
+
+func (expr *BetweenExpr) Eval(data map[string]interface{}) (interface{}, error) {
+	evaled, err := expr.Expr.Eval(data)
+	if err != nil {
+		return nil, err
+	}
+
+	low, err := expr.Low.Eval(data)
+	if err != nil {
+		return nil, err
+	}
+
+	high, err := expr.High.Eval(data)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := func() (interface{}, error) {
+		lower, err := op(evaled, Operator(lexer.GreaterThanEqual), low)
+		if err != nil {
+			return nil, err
+		}
+
+		upper, err := op(evaled, Operator(lexer.LessThanEqual), high)
+		if err != nil {
+			return nil, err
+		}
+
+		between, _ := op(lower, Operator(lexer.And), upper)
+		if expr.Not {
+			return unaryOp(Operator(lexer.Not), between)
+		}
+		return between, nil
+	}()
+	if err != nil {
+		if expr.Permissive {
+			return nil, nil
+		}
+		return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
 	}
 
-	return ret, nil
+	return val, nil
 }
 
-func (expr *JQ) Eval(data map[string]interface{}) (interface{}, error) {
+func (expr *MatchExpr) Eval(data map[string]interface{}) (interface{}, error) {
 	evaled, err := expr.Expr.Eval(data)
 	if err != nil {
 		return nil, err
 	}
 
-	outs, err := filq.Run(filq.NewContext(), expr.Path, evaled)
+	pattern, err := expr.Pattern.Eval(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if evaled == nil || pattern == nil {
+		return nil, nil
+	}
+
+	evaled, secret := revealSecret(evaled)
+	pattern, patternSecret := revealSecret(pattern)
+
+	str, ok := evaled.(string)
+	pat, patOk := pattern.(string)
+	if !ok || !patOk {
+		if expr.Permissive {
+			return nil, nil
+		}
+		return nil, &EvalError{Offset: expr.Offset, Msg: typeMismatch(expr.Op, TypeOf(evaled), TypeOf(pattern)).Error()}
+	}
+
+	re, err := expr.Regexp(pat)
+	if err != nil {
+		return nil, redactError(&EvalError{Offset: expr.Offset, Msg: err.Error()}, concealSecret(pat, patternSecret))
+	}
+
+	return concealSecret(re.MatchString(str) != expr.Not, secret || patternSecret), nil
+}
+
+// Regexp returns the compiled regular expression for a pattern.
+func (expr *MatchExpr) Regexp(pattern string) (*regexp.Regexp, error) {
+	switch lexer.TokenType(expr.Op) {
+	case lexer.Like:
+		return compileRegexp(likeToRegexp(pattern), "")
+	case lexer.ILike:
+		return compileRegexp(likeToRegexp(pattern), "i")
+	case lexer.IMatch:
+		return compileRegexp(pattern, "i")
+	}
+	return compileRegexp(pattern, "")
+}
+
+// likeToRegexp converts a LIKE pattern, where % matches any sequence of
+// characters and _ matches any single character, to an anchored regular
+// expression. A backslash matches the following character literally.
+func likeToRegexp(pattern string) string {
+	var buf bytes.Buffer
+	buf.WriteString("(?s)^")
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '%':
+			buf.WriteString(".*")
+		case '_':
+			buf.WriteString(".")
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			fallthrough
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+	buf.WriteString("$")
+
+	return buf.String()
+}
+
+func (expr *CaseExpr) Eval(data map[string]interface{}) (interface{}, error) {
+	var operand interface{}
+	if expr.Operand != nil {
+		var err error
+		if operand, err = expr.Operand.Eval(data); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, when := range expr.Whens {
+		condition, err := when.Condition.Eval(data)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := !checkIfEmpty(condition)
+		if expr.Operand != nil {
+			equal, err := op(operand, Operator(lexer.Equal), condition)
+			if err != nil {
+				if expr.Permissive {
+					return nil, nil
+				}
+				return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
+			}
+			equal, _ = revealSecret(equal)
+			matched = equal == true
+		}
+
+		if matched {
+			return when.Result.Eval(data)
+		}
+	}
+
+	if expr.Else != nil {
+		return expr.Else.Eval(data)
+	}
+
+	return nil, nil
+}
+
+func (expr *Call) Eval(data map[string]interface{}) (interface{}, error) {
+	val, err := expr.evalCall(data)
 	if err != nil {
-		return nil, fmt.Errorf("jq error, %v", err)
+		return nil, err
 	}
 
 	if expr.PathExpr != nil {
-		return matchPathExpression(outs, expr.PathExpr.Fields)
+		return matchPathExpression(val, expr.PathExpr.Steps, data)
 	}
 
-	return outs, nil
+	return val, nil
 }
 
-func (expr *Subselect) Eval(data map[string]interface{}) (interface{}, error) {
-	return expr.SelectEval(data)
+// maxCachedResults is the number of results of a deterministic function a
+// call caches before the cache is started over, so that a call evaluated with
+// ever different arguments doesn't keep every result.
+const maxCachedResults = 256
+
+func (expr *Call) evalCall(data map[string]interface{}) (interface{}, error) {
+	if expr.folded {
+		return expr.value, nil
+	}
+
+	args, err := evalArgs(expr.Args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expr.Func.checkArgs(expr.Func.Name, args); err != nil {
+		if expr.Permissive {
+			return nil, nil
+		}
+		return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
+	}
+
+	key, cacheable := "", false
+	if expr.Func.Deterministic {
+		key, cacheable = cacheKey(args)
+		if val, ok := expr.cache[key]; cacheable && ok {
+			return val, nil
+		}
+	}
+
+	val, err := expr.Func.call(args, &expr.programs, data)
+	if err != nil {
+		return nil, &EvalError{Offset: expr.errorOffset(err), Msg: err.Error()}
+	}
+
+	if cacheable {
+		if expr.cache == nil || len(expr.cache) >= maxCachedResults {
+			expr.cache = make(map[string]interface{})
+		}
+		expr.cache[key] = val
+	}
+
+	return val, nil
 }
 
-func op(lhs interface{}, op Operator, rhs interface{}) (val interface{}) {
+// errorOffset returns the position in the query source of the cause of an
+// error: the argument the error is about, when its position is known, or
+// otherwise the function name.
+func (expr *Call) errorOffset(err error) int {
+	if e, ok := err.(*argError); ok && e.index < len(expr.Args) {
+		switch arg := expr.Args[e.index].(type) {
+		case *String:
+			return arg.Offset
+		case *Parameter:
+			return arg.Offset
+		}
+	}
+	return expr.Offset
+}
+
+// Step evaluates the arguments of an aggregate call for a row, and passes
+// them to the Aggregator.
+func (expr *AggregateCall) Step(data map[string]interface{}) error {
+	args, err := evalArgs(expr.Args, data)
+	if err != nil {
+		return err
+	}
+
+	secret, err := stepAggregate(expr.Func, expr.Aggregator, args, expr.Permissive)
+	expr.secret = expr.secret || secret
+	if err != nil {
+		return &EvalError{Offset: expr.Offset, Msg: err.Error()}
+	}
+
+	return nil
+}
+
+// stepAggregate passes a row's arguments to an aggregator. Rows where an
+// argument is null are skipped, as are rows with arguments of the wrong type
+// when permissive. It reports whether an argument was a secret.
+func stepAggregate(fn *AggregateFunction, aggregator Aggregator, args []interface{}, permissive bool) (secret bool, err error) {
+	secrets := append([]interface{}(nil), args...)
 	defer func() {
-		if r := recover(); r != nil {
-			val = nil
+		err = redactError(err, secrets...)
+	}()
+
+	if err := fn.checkArgs(fn.Name, args); err != nil {
+		if permissive {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for i, arg := range args {
+		if arg == nil {
+			return secret, nil
+		}
+
+		var revealed bool
+		args[i], revealed = revealSecret(arg)
+		secret = secret || revealed
+	}
+	if err := fn.convertArgs(args); err != nil {
+		return secret, err
+	}
+
+	return secret, aggregator.Step(args)
+}
+
+// Reset gives the call a new Aggregator, ready for a new set of rows.
+func (expr *AggregateCall) Reset() {
+	expr.Aggregator = expr.Func.New()
+	expr.secret = false
+}
+
+func (expr *AggregateCall) Eval(data map[string]interface{}) (interface{}, error) {
+	if expr.Aggregator == nil {
+		return nil, &EvalError{Offset: expr.Offset, Msg: fmt.Sprintf("aggregate function %v() used outside of an aggregate", expr.Name)}
+	}
+
+	val, err := expr.Aggregator.Result()
+	if err != nil {
+		return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
+	}
+	val = concealSecret(val, expr.secret)
+
+	if expr.PathExpr != nil {
+		return matchPathExpression(val, expr.PathExpr.Steps, data)
+	}
+
+	return val, nil
+}
+
+func (expr *WindowCall) Eval(data map[string]interface{}) (interface{}, error) {
+	if expr.Results == nil {
+		return nil, &EvalError{Offset: expr.Offset, Msg: fmt.Sprintf("window function %v() used outside of a window", expr.Name)}
+	}
+
+	val := expr.Results[expr.Row]
+	if expr.PathExpr != nil {
+		return matchPathExpression(val, expr.PathExpr.Steps, data)
+	}
+
+	return val, nil
+}
+
+func evalArgs(exprs []Expr, data map[string]interface{}) ([]interface{}, error) {
+	args := make([]interface{}, len(exprs))
+	for i, arg := range exprs {
+		evaled, err := arg.Eval(data)
+		if err != nil {
+			return nil, err
 		}
+		args[i] = evaled
+	}
+
+	return args, nil
+}
+
+func (expr *SetReturningCall) Eval(data map[string]interface{}) (val interface{}, err error) {
+	args, err := evalArgs(expr.Args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// errors don't give away the values of secret arguments
+	secrets := append([]interface{}(nil), args...)
+	defer func() {
+		err = redactError(err, secrets...)
 	}()
 
-	// convert rhs to the same type as lhs
-	switch rhs.(type) {
-	// in the instance of floats, convert lhs to rhs type instead, so that
-	// 1>=1.1 isn't true due to truncating
-	case float64:
-		lhs = reflect.ValueOf(lhs).Convert(reflect.TypeOf(rhs)).Interface()
-	default:
-		rhs = reflect.ValueOf(rhs).Convert(reflect.TypeOf(lhs)).Interface()
+	if err := expr.Func.checkArgs(expr.Func.Name, args); err != nil {
+		if expr.Permissive {
+			return []interface{}{}, nil
+		}
+		return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
 	}
 
-	switch lexer.TokenType(op) {
-	case lexer.Or:
-		switch rhs := rhs.(type) {
-		case bool:
-			return lhs.(bool) || rhs
+	secret := false
+	for i, arg := range args {
+		if arg == nil {
+			return []interface{}{}, nil
+		}
+
+		var revealed bool
+		args[i], revealed = revealSecret(arg)
+		secret = secret || revealed
+	}
+	if err := expr.Func.convertArgs(args); err != nil {
+		return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
+	}
+
+	eval := expr.Func.Eval
+	if expr.Func.Compile != nil {
+		eval, err = expr.programs.compile(expr.Func, args[expr.Func.Program].(string))
+		if err != nil {
+			return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
+		}
+	}
+
+	rows, err := eval(args)
+	if err != nil {
+		return nil, &EvalError{Offset: expr.Offset, Msg: err.Error()}
+	}
+
+	columns := expr.Columns()
+
+	result := make([]interface{}, 0, len(rows))
+	for i, row := range rows {
+		for j := range row {
+			row[j] = concealSecret(row[j], secret)
+		}
+		if expr.Ordinality {
+			row = append(row, int64(i+1))
+		}
+
+		if len(columns) == 1 {
+			result = append(result, row[0])
+			continue
+		}
+
+		obj := make(map[string]interface{}, len(columns))
+		for j, column := range columns {
+			obj[column] = row[j]
 		}
+		result = append(result, obj)
+	}
+
+	return result, nil
+}
+
+func (expr *Subselect) Eval(data map[string]interface{}) (interface{}, error) {
+	return expr.SelectEval(data)
+}
+
+func op(lhs interface{}, op Operator, rhs interface{}) (val interface{}, err error) {
+	defer func(lhs, rhs interface{}) {
+		err = redactError(err, lhs, rhs)
+	}(lhs, rhs)
+
+	lhs, lsecret := revealSecret(lhs)
+	rhs, rsecret := revealSecret(rhs)
+	if lsecret || rsecret {
+		defer func() {
+			val = concealSecret(val, true)
+		}()
+	}
+
+	lt, rt := TypeOf(lhs), TypeOf(rhs)
+	if _, ok := operatorType(op, lt, rt); !ok || lt == AnyType || rt == AnyType {
+		return nil, typeMismatch(op, lt, rt)
+	}
+
+	switch lexer.TokenType(op) {
 	case lexer.And:
-		switch rhs := rhs.(type) {
-		case bool:
-			return lhs.(bool) && rhs
+		switch {
+		case lhs == false || rhs == false:
+			return false, nil
+		case lhs == nil || rhs == nil:
+			return nil, nil
 		}
+		return true, nil
+
+	case lexer.Or:
+		switch {
+		case lhs == true || rhs == true:
+			return true, nil
+		case lhs == nil || rhs == nil:
+			return nil, nil
+		}
+		return false, nil
+	}
+
+	// any other operation involving null is null
+	if lhs == nil || rhs == nil {
+		return nil, nil
+	}
+
+	if lexer.TokenType(op) == lexer.Concat {
+		return toText(lhs) + toText(rhs), nil
+	}
+
+	if isTemporal(lt) || isTemporal(rt) {
+		return temporalOp(op, lhs, rhs)
+	}
+
+	if lt == QuantityType || rt == QuantityType {
+		return quantityOp(op, lhs, rhs)
+	}
+
+	if lt.IsNumeric() && rt.IsNumeric() {
+		return numericOp(op, lhs, rhs)
+	}
+
+	switch lexer.TokenType(op) {
+	case lexer.Contains:
+		return jsonContains(lhs, rhs), nil
+	case lexer.ContainedBy:
+		return jsonContains(rhs, lhs), nil
+	case lexer.HasKey, lexer.HasAnyKey, lexer.HasAllKeys:
+		return jsonHasKeys(op, lhs, rhs)
 	case lexer.LessThan:
 		switch rhs := rhs.(type) {
-		case int:
-			return lhs.(int) < rhs
-		case int64:
-			return lhs.(int64) < rhs
-		case float64:
-			return lhs.(float64) < rhs
+		case string:
+			return lhs.(string) < rhs, nil
 		}
 	case lexer.LessThanEqual:
 		switch rhs := rhs.(type) {
-		case int:
-			return lhs.(int) <= rhs
-		case int64:
-			return lhs.(int64) <= rhs
-		case float64:
-			return lhs.(float64) <= rhs
+		case string:
+			return lhs.(string) <= rhs, nil
 		}
 	case lexer.GreaterThan:
 		switch rhs := rhs.(type) {
-		case int:
-			return lhs.(int) > rhs
-		case int64:
-			return lhs.(int64) > rhs
-		case float64:
-			return lhs.(float64) > rhs
+		case string:
+			return lhs.(string) > rhs, nil
 		}
 	case lexer.GreaterThanEqual:
 		switch rhs := rhs.(type) {
-		case int:
-			return lhs.(int) >= rhs
-		case int64:
-			return lhs.(int64) >= rhs
-		case float64:
-			return lhs.(float64) >= rhs
+		case string:
+			return lhs.(string) >= rhs, nil
 		}
 	case lexer.Equal:
 		switch rhs := rhs.(type) {
 		case bool:
-			return lhs.(bool) == rhs
-		case int:
-			return lhs.(int) == rhs
-		case int64:
-			return lhs.(int64) == rhs
-		case float64:
-			return lhs.(float64) == rhs
+			return lhs.(bool) == rhs, nil
 		case string:
-			return lhs.(string) == rhs
+			return lhs.(string) == rhs, nil
+		case map[string]interface{}, joiner.Tuple, []interface{}:
+			return jsonEqual(lhs, rhs), nil
 		}
 	case lexer.NotEqual:
 		switch rhs := rhs.(type) {
 		case bool:
-			return lhs.(bool) != rhs
-		case int:
-			return lhs.(int) != rhs
-		case int64:
-			return lhs.(int64) != rhs
-		case float64:
-			return lhs.(float64) != rhs
+			return lhs.(bool) != rhs, nil
 		case string:
-			return lhs.(string) != rhs
-		}
-	case lexer.Subtract:
-		switch rhs := rhs.(type) {
-		case int:
-			return lhs.(int) - rhs
-		case int64:
-			return lhs.(int64) - rhs
-		case float64:
-			return lhs.(float64) - rhs
-		}
-	case lexer.Add:
-		switch rhs := rhs.(type) {
-		case int:
-			return lhs.(int) + rhs
-		case int64:
-			return lhs.(int64) + rhs
-		case float64:
-			return lhs.(float64) + rhs
-		}
-	case lexer.Multiply:
-		switch rhs := rhs.(type) {
-		case int:
-			return lhs.(int) * rhs
-		case int64:
-			return lhs.(int64) * rhs
-		case float64:
-			return lhs.(float64) * rhs
-		}
-	case lexer.Divide:
-		switch rhs := rhs.(type) {
-		case int:
-			return lhs.(int) / rhs
-		case int64:
-			return lhs.(int64) / rhs
-		case float64:
-			return lhs.(float64) / rhs
+			return lhs.(string) != rhs, nil
+		case map[string]interface{}, joiner.Tuple, []interface{}:
+			return !jsonEqual(lhs, rhs), nil
 		}
 	}
 
-	return nil
+	return nil, typeMismatch(op, lt, rt)
 }
 
-func matchPathExpression(content interface{}, fields []string) (interface{}, error) {
-	if len(fields) == 0 {
-		return content, nil
+func unaryOp(op Operator, val interface{}) (result interface{}, err error) {
+	defer func(val interface{}) {
+		err = redactError(err, val)
+	}(val)
+
+	val, secret := revealSecret(val)
+	if secret {
+		defer func() {
+			result = concealSecret(result, true)
+		}()
 	}
 
-	switch v := content.(type) {
-	case joiner.Tuple:
-		child, ok := v[fields[0]]
-		if !ok {
-			return nil, nil
-		}
-		return matchPathExpression(child, fields[1:])
+	t := TypeOf(val)
+	if _, ok := unaryOperatorType(op, t); !ok || t == AnyType {
+		return nil, unaryTypeMismatch(op, t)
+	}
 
-	case map[string]interface{}:
-		child, ok := v[fields[0]]
-		if !ok {
-			return nil, nil
-		}
-		return matchPathExpression(child, fields[1:])
+	if val == nil {
+		return nil, nil
+	}
 
-	case []interface{}:
-		// parse number
-		i, err := strconv.Atoi(fields[0])
-		if err != nil {
-			return nil, nil
+	switch lexer.TokenType(op) {
+	case lexer.Not:
+		return !val.(bool), nil
+
+	case lexer.Subtract:
+		switch v := val.(type) {
+		case Duration:
+			return -v, nil
+		case resource.Quantity:
+			q := v.DeepCopy()
+			q.Neg()
+			return q, nil
 		}
 
-		if i >= 0 && i < len(v) {
-			return matchPathExpression(v[i], fields[1:])
+		num, _ := toNumber(val)
+		switch num := num.(type) {
+		case int64:
+			if num == math.MinInt64 {
+				return nil, errIntegerOverflow
+			}
+			return -num, nil
+		case float64:
+			return -num, nil
 		}
-		return nil, nil
 	}
 
-	return content, nil
+	return nil, unaryTypeMismatch(op, t)
 }
@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"time"
+
 	"github.com/saracen/kubeql/query/lexer"
 )
 
@@ -25,13 +27,18 @@ func (o Operator) Precedence() int {
 		return 1
 	case lexer.And:
 		return 2
-	case lexer.Equal, lexer.NotEqual, lexer.LessThan, lexer.LessThanEqual,
-		lexer.GreaterThan, lexer.GreaterThanEqual:
+	case lexer.Not:
 		return 3
-	case lexer.Add, lexer.Subtract:
+	case lexer.Equal, lexer.NotEqual, lexer.LessThan, lexer.LessThanEqual,
+		lexer.GreaterThan, lexer.GreaterThanEqual, lexer.Contains,
+		lexer.ContainedBy, lexer.HasKey, lexer.HasAnyKey, lexer.HasAllKeys:
 		return 4
-	case lexer.Multiply, lexer.Divide:
+	case lexer.Concat:
 		return 5
+	case lexer.Add, lexer.Subtract:
+		return 6
+	case lexer.Multiply, lexer.Divide, lexer.Modulo:
+		return 7
 	}
 	return 0
 }
@@ -39,18 +46,89 @@ func (o Operator) Precedence() int {
 func (o Operator) IsOperator() bool {
 	switch lexer.TokenType(o) {
 	case lexer.And, lexer.Or, lexer.Add, lexer.Subtract, lexer.Multiply,
-		lexer.Divide, lexer.Equal, lexer.NotEqual, lexer.LessThan,
-		lexer.LessThanEqual, lexer.GreaterThan, lexer.GreaterThanEqual:
+		lexer.Divide, lexer.Modulo, lexer.Equal, lexer.NotEqual, lexer.LessThan,
+		lexer.LessThanEqual, lexer.GreaterThan, lexer.GreaterThanEqual,
+		lexer.Concat, lexer.Contains, lexer.ContainedBy, lexer.HasKey,
+		lexer.HasAnyKey, lexer.HasAllKeys:
 		return true
 	}
 
 	return false
 }
 
+func (o Operator) String() string {
+	switch lexer.TokenType(o) {
+	case lexer.And:
+		return "AND"
+	case lexer.Or:
+		return "OR"
+	case lexer.Not:
+		return "NOT"
+	case lexer.Concat:
+		return "||"
+	case lexer.Add:
+		return "+"
+	case lexer.Subtract:
+		return "-"
+	case lexer.Multiply:
+		return "*"
+	case lexer.Divide:
+		return "/"
+	case lexer.Modulo:
+		return "%"
+	case lexer.Equal:
+		return "="
+	case lexer.NotEqual:
+		return "!="
+	case lexer.LessThan:
+		return "<"
+	case lexer.LessThanEqual:
+		return "<="
+	case lexer.GreaterThan:
+		return ">"
+	case lexer.GreaterThanEqual:
+		return ">="
+	case lexer.In:
+		return "IN"
+	case lexer.Union:
+		return "UNION"
+	case lexer.Intersect:
+		return "INTERSECT"
+	case lexer.Except:
+		return "EXCEPT"
+	case lexer.Between:
+		return "BETWEEN"
+	case lexer.Like:
+		return "LIKE"
+	case lexer.ILike:
+		return "ILIKE"
+	case lexer.Match:
+		return "~"
+	case lexer.IMatch:
+		return "~*"
+	case lexer.Contains:
+		return "@>"
+	case lexer.ContainedBy:
+		return "<@"
+	case lexer.HasKey:
+		return "?"
+	case lexer.HasAnyKey:
+		return "?|"
+	case lexer.HasAllKeys:
+		return "?&"
+	}
+	return "?"
+}
+
 type BinaryExpr struct {
 	Op  Operator
 	LHS Expr
 	RHS Expr
+
+	// Offset is the position of the operator in the query source
+	Offset int
+	// Permissive evaluates type mismatches to nil rather than an error
+	Permissive bool
 }
 
 func (expr *BinaryExpr) Walk(v Visitor) Expr {
@@ -63,6 +141,135 @@ func (expr *BinaryExpr) Walk(v Visitor) Expr {
 	return expr
 }
 
+type UnaryExpr struct {
+	Op   Operator
+	Expr Expr
+
+	// Offset is the position of the operator in the query source
+	Offset int
+	// Permissive evaluates type mismatches to nil rather than an error
+	Permissive bool
+}
+
+func (expr *UnaryExpr) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+	expr.Expr.Walk(v)
+
+	return expr
+}
+
+// InExpr tests whether an expression is equal to any of a list of expressions.
+type InExpr struct {
+	Expr Expr
+	List []Expr
+	Not  bool
+
+	// Offset is the position of the operator in the query source
+	Offset int
+	// Permissive evaluates type mismatches to nil rather than an error
+	Permissive bool
+}
+
+func (expr *InExpr) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+	expr.Expr.Walk(v)
+	for _, item := range expr.List {
+		item.Walk(v)
+	}
+
+	return expr
+}
+
+// BetweenExpr tests whether an expression is within an inclusive range.
+type BetweenExpr struct {
+	Expr Expr
+	Low  Expr
+	High Expr
+	Not  bool
+
+	// Offset is the position of the operator in the query source
+	Offset int
+	// Permissive evaluates type mismatches to nil rather than an error
+	Permissive bool
+}
+
+func (expr *BetweenExpr) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+	expr.Expr.Walk(v)
+	expr.Low.Walk(v)
+	expr.High.Walk(v)
+
+	return expr
+}
+
+// MatchExpr matches a string against a LIKE, ILIKE or regular expression
+// pattern. Compiled patterns are cached, so that they're not compiled for
+// every row.
+type MatchExpr struct {
+	Op      Operator
+	Expr    Expr
+	Pattern Expr
+	Not     bool
+
+	// Offset is the position of the operator in the query source
+	Offset int
+	// Permissive evaluates type mismatches to nil rather than an error
+	Permissive bool
+}
+
+func (expr *MatchExpr) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+	expr.Expr.Walk(v)
+	expr.Pattern.Walk(v)
+
+	return expr
+}
+
+// CaseExpr evaluates to the result of the first WHEN clause that matches. With
+// an Operand, the WHEN conditions are compared to it for equality, otherwise
+// each condition is tested in turn.
+type CaseExpr struct {
+	Operand Expr
+	Whens   []*WhenClause
+	Else    Expr
+
+	// Offset is the position of the CASE keyword in the query source
+	Offset int
+	// Permissive evaluates type mismatches to nil rather than an error
+	Permissive bool
+}
+
+type WhenClause struct {
+	Condition Expr
+	Result    Expr
+}
+
+func (expr *CaseExpr) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+	if expr.Operand != nil {
+		expr.Operand.Walk(v)
+	}
+	for _, when := range expr.Whens {
+		when.Condition.Walk(v)
+		when.Result.Walk(v)
+	}
+	if expr.Else != nil {
+		expr.Else.Walk(v)
+	}
+
+	return expr
+}
+
 type ParenExpr struct {
 	Expr     Expr
 	PathExpr *PathExpression
@@ -74,12 +281,53 @@ func (expr *ParenExpr) Walk(v Visitor) Expr {
 	}
 
 	expr.Expr.Walk(v)
+	if expr.PathExpr != nil {
+		expr.PathExpr.Walk(v)
+	}
+
+	return expr
+}
+
+// ObjectLiteral is an object, such as {name: 'web', 'app.kubernetes.io/name': x}.
+type ObjectLiteral struct {
+	Keys   []string
+	Values []Expr
+}
+
+func (expr *ObjectLiteral) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+
+	for _, val := range expr.Values {
+		val.Walk(v)
+	}
+
+	return expr
+}
+
+// ArrayLiteral is an array, such as [1, 'two', pods->metadata->name].
+type ArrayLiteral struct {
+	Elements []Expr
+}
+
+func (expr *ArrayLiteral) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+
+	for _, elem := range expr.Elements {
+		elem.Walk(v)
+	}
 
 	return expr
 }
 
 type String struct {
 	Val string
+
+	// Offset is the position of the start of the literal in the query source
+	Offset int
 }
 
 func (expr *String) Walk(v Visitor) Expr {
@@ -91,7 +339,7 @@ func (expr *String) Walk(v Visitor) Expr {
 }
 
 type Integer struct {
-	Val int
+	Val int64
 }
 
 func (expr *Integer) Walk(v Visitor) Expr {
@@ -114,6 +362,30 @@ func (expr *Float) Walk(v Visitor) Expr {
 	return expr
 }
 
+type Interval struct {
+	Val Duration
+}
+
+func (expr *Interval) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+
+	return expr
+}
+
+type Timestamp struct {
+	Val time.Time
+}
+
+func (expr *Timestamp) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+
+	return expr
+}
+
 type Boolean struct {
 	Val bool
 }
@@ -136,37 +408,171 @@ func (expr *Reference) Walk(v Visitor) Expr {
 		return expr
 	}
 
+	if expr.PathExpr != nil {
+		expr.PathExpr.Walk(v)
+	}
+
 	return expr
 }
 
-type JsonPath struct {
-	Expr     Expr
-	Path     string
+// Parameter is a placeholder for a value bound when the query is executed:
+// $1 is the first positional value, and :name a named value. Name is the
+// placeholder as written, and values are passed to Eval under it.
+type Parameter struct {
+	Name     string
 	PathExpr *PathExpression
+
+	// Offset is the position of the parameter in the query source
+	Offset int
 }
 
-func (expr *JsonPath) Walk(v Visitor) Expr {
+func (expr *Parameter) Walk(v Visitor) Expr {
 	if v = v.Visit(expr); v == nil {
 		return expr
 	}
 
-	expr.Expr.Walk(v)
+	if expr.PathExpr != nil {
+		expr.PathExpr.Walk(v)
+	}
 
 	return expr
 }
 
-type JQ struct {
-	Expr     Expr
-	Path     string
+// Call is a call to a function in the function registry.
+type Call struct {
+	Name     string
+	Args     []Expr
 	PathExpr *PathExpression
+	Func     *Function
+
+	// Offset is the position of the function name in the query source
+	Offset int
+	// Permissive evaluates type mismatches to nil rather than an error
+	Permissive bool
+
+	// results of deterministic functions, by their arguments, up to
+	// maxCachedResults
+	cache map[string]interface{}
+	// the compiled program of functions with a program argument
+	programs programCache
+	// folded calls have constant arguments, and were evaluated once
+	folded bool
+	value  interface{}
 }
 
-func (expr *JQ) Walk(v Visitor) Expr {
+func (expr *Call) Walk(v Visitor) Expr {
 	if v = v.Visit(expr); v == nil {
 		return expr
 	}
 
-	expr.Expr.Walk(v)
+	for _, arg := range expr.Args {
+		arg.Walk(v)
+	}
+	if expr.PathExpr != nil {
+		expr.PathExpr.Walk(v)
+	}
+
+	return expr
+}
+
+// AggregateCall is a call to an aggregate function. The executor steps the
+// Aggregator through each row, before the call is evaluated for the result.
+type AggregateCall struct {
+	Name     string
+	Args     []Expr
+	PathExpr *PathExpression
+	Func     *AggregateFunction
+
+	Aggregator Aggregator
+
+	// secret is set once a value from a secret has been aggregated
+	secret bool
+
+	// Offset is the position of the function name in the query source
+	Offset int
+	// Permissive evaluates type mismatches to nil rather than an error
+	Permissive bool
+}
+
+func (expr *AggregateCall) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+
+	for _, arg := range expr.Args {
+		arg.Walk(v)
+	}
+	if expr.PathExpr != nil {
+		expr.PathExpr.Walk(v)
+	}
+
+	return expr
+}
+
+// WindowCall is a call to a window function, or to an aggregate function with
+// an OVER clause. The executor evaluates the call for every row once every row
+// has been seen, then sets Row to evaluate the result for each row.
+type WindowCall struct {
+	Name      string
+	Args      []Expr
+	PathExpr  *PathExpression
+	Func      *WindowFunction
+	Aggregate *AggregateFunction
+	Window    *Window
+
+	Results []interface{}
+	Row     int
+
+	// Offset is the position of the function name in the query source
+	Offset int
+	// Permissive evaluates type mismatches to nil rather than an error
+	Permissive bool
+}
+
+func (expr *WindowCall) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+
+	for _, arg := range expr.Args {
+		arg.Walk(v)
+	}
+	expr.Window.Walk(v)
+	if expr.PathExpr != nil {
+		expr.PathExpr.Walk(v)
+	}
+
+	return expr
+}
+
+// SetReturningCall is a call to a set-returning function in the FROM clause.
+// It evaluates to a slice of rows, each the value of the function's single
+// column, or an object of its columns.
+type SetReturningCall struct {
+	Name string
+	Args []Expr
+	Func *SetReturningFunction
+
+	// Ordinality adds an ordinality column, numbering the rows from 1
+	Ordinality bool
+
+	// Offset is the position of the function name in the query source
+	Offset int
+	// Permissive evaluates type mismatches to no rows rather than an error
+	Permissive bool
+
+	// the compiled program of functions with a program argument
+	programs setProgramCache
+}
+
+func (expr *SetReturningCall) Walk(v Visitor) Expr {
+	if v = v.Visit(expr); v == nil {
+		return expr
+	}
+
+	for _, arg := range expr.Args {
+		arg.Walk(v)
+	}
 
 	return expr
 }
@@ -176,10 +582,13 @@ func (expr *Subselect) Walk(v Visitor) Expr {
 		return expr
 	}
 
-	for _, expression := range expr.Select.SelectClause.Expressions {
-		expression.Condition.Walk(v)
+	if expr.Select.Compound != nil {
+		expr.Select.Compound.Walk(v)
+		return expr
 	}
 
+	expr.Select.SelectClause.Walk(v)
+
 	return expr
 }
 
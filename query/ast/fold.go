@@ -0,0 +1,89 @@
+package ast
+
+// Fold evaluates calls to deterministic and stable functions whose arguments
+// are all constant, so that they're evaluated once rather than for every row,
+// and returns the calls it folded. data is the data of the execution, which
+// holds its parameters and the time it started. Calls that fail are left to
+// report their error when evaluated. Calls already folded are kept, so that
+// stable functions return the same result throughout an execution of a query.
+func Fold(walker ExprWalker, data map[string]interface{}) []*Call {
+	var folded []*Call
+	Inspect(walker, func(expr Expr) bool {
+		call, ok := expr.(*Call)
+		if !ok || call.folded || !IsConstant(call) {
+			return true
+		}
+
+		val, err := call.evalCall(data)
+		if err != nil {
+			return true
+		}
+		call.folded, call.value = true, val
+		folded = append(folded, call)
+
+		return false
+	})
+	return folded
+}
+
+// Unfold discards the results of folded calls, so that they're evaluated again
+// by the next execution of the query.
+func Unfold(calls []*Call) {
+	for _, call := range calls {
+		call.folded, call.value = false, nil
+	}
+}
+
+// IsConstant reports whether an expression evaluates to the same value for
+// every row.
+func IsConstant(expr Expr) bool {
+	switch expr := expr.(type) {
+	case *String, *Integer, *Float, *Boolean, *Interval, *Timestamp:
+		return true
+
+	case *ParenExpr:
+		if expr.PathExpr != nil {
+			for _, step := range expr.PathExpr.Steps {
+				if step.Kind == PathComputed && !IsConstant(step.Expr) {
+					return false
+				}
+			}
+		}
+		return IsConstant(expr.Expr)
+
+	case *UnaryExpr:
+		return IsConstant(expr.Expr)
+
+	case *BinaryExpr:
+		return IsConstant(expr.LHS) && IsConstant(expr.RHS)
+
+	case *ObjectLiteral:
+		for _, val := range expr.Values {
+			if !IsConstant(val) {
+				return false
+			}
+		}
+		return true
+
+	case *ArrayLiteral:
+		for _, elem := range expr.Elements {
+			if !IsConstant(elem) {
+				return false
+			}
+		}
+		return true
+
+	case *Call:
+		if !expr.Func.Deterministic && !expr.Func.Stable {
+			return false
+		}
+		for _, arg := range expr.Args {
+			if !IsConstant(arg) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
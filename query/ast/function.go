@@ -0,0 +1,471 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Signature declares the arguments and result of a function.
+type Signature struct {
+	// Args are the types of the function's arguments. AnyType accepts any
+	// value, and FloatType also accepts integers, which are converted.
+	Args []Type
+	// MinArgs is the number of required arguments. Arguments beyond MinArgs
+	// are optional.
+	MinArgs int
+	// Variadic functions accept any number of additional arguments of the
+	// last type in Args.
+	Variadic bool
+
+	// Returns is the type of the function's result.
+	Returns Type
+}
+
+// Function is a scalar function that can be called from a query.
+type Function struct {
+	Name string
+	Signature
+
+	// CallOnNull calls the function when an argument is null. Otherwise, a
+	// null argument results in null without the function being called.
+	CallOnNull bool
+
+	// Deterministic functions always return the same result for the same
+	// arguments. Calls with constant arguments are evaluated once when the
+	// query is planned, and results are cached for repeated arguments.
+	Deterministic bool
+
+	// Stable functions return the same result throughout an execution of a
+	// query, such as now(), but not across executions. Calls with constant
+	// arguments are evaluated once per execution, and results aren't cached.
+	Stable bool
+
+	// Now functions, such as now() and age(), are passed the time the
+	// execution of the query started after their arguments, so that they
+	// agree on the current time throughout an execution.
+	Now bool
+
+	Eval func(args []interface{}) (interface{}, error)
+
+	// Compile, if set, compiles the string argument at index Program, such
+	// as a CEL expression, returning the function that evaluates the call in
+	// place of Eval. Each call compiles its program once, rather than for
+	// every row, and a null program evaluates to null.
+	Compile func(program string) (func(args []interface{}) (interface{}, error), error)
+	Program int
+}
+
+// AggregateFunction is a function computed over the rows of a result.
+type AggregateFunction struct {
+	Name string
+	Signature
+
+	// New returns an Aggregator for a new set of rows.
+	New func() Aggregator
+}
+
+// Aggregator accumulates the rows of an aggregate function.
+type Aggregator interface {
+	// Step is called with the arguments of each row. Rows where an argument
+	// is null are skipped.
+	Step(args []interface{}) error
+
+	// Result returns the aggregated result once all rows have been seen.
+	Result() (interface{}, error)
+}
+
+// SetReturningFunction is a function that returns a set of rows, called as an
+// item of the FROM clause.
+type SetReturningFunction struct {
+	Name string
+	Signature
+
+	// Columns are the names of the columns of each row. The rows of a
+	// function with a single column are the column's value.
+	Columns []string
+
+	// Eval returns the rows of the function's result. It isn't called when
+	// an argument is null, which returns no rows.
+	Eval func(args []interface{}) ([][]interface{}, error)
+
+	// Compile and Program are as for Function, for set-returning functions
+	// with a program argument.
+	Compile func(program string) (func(args []interface{}) ([][]interface{}, error), error)
+	Program int
+}
+
+// WindowFunction is a function computed for each row from the rows of its
+// partition, called with an OVER clause.
+type WindowFunction struct {
+	Name string
+	Signature
+
+	// Eval returns the result for a row of a partition.
+	Eval func(p *WindowPartition, row int) (interface{}, error)
+}
+
+// registry holds the registered functions by their lower-case names. It's
+// locked, so that functions can be registered while queries are parsed.
+var registry = struct {
+	sync.RWMutex
+	functions    map[string]*Function
+	aggregates   map[string]*AggregateFunction
+	setReturning map[string]*SetReturningFunction
+	windows      map[string]*WindowFunction
+}{
+	functions:    make(map[string]*Function),
+	aggregates:   make(map[string]*AggregateFunction),
+	setReturning: make(map[string]*SetReturningFunction),
+	windows:      make(map[string]*WindowFunction),
+}
+
+// RegisterFunction adds a function to the function registry, replacing any
+// existing function of the same name. Function names are case-insensitive.
+func RegisterFunction(fn *Function) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.functions[strings.ToLower(fn.Name)] = fn
+}
+
+// LookupFunction returns the registered function with the given name, or nil.
+func LookupFunction(name string) *Function {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.functions[strings.ToLower(name)]
+}
+
+// RegisterAggregate adds an aggregate function to the function registry,
+// replacing any existing aggregate function of the same name.
+func RegisterAggregate(fn *AggregateFunction) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.aggregates[strings.ToLower(fn.Name)] = fn
+}
+
+// LookupAggregate returns the registered aggregate function with the given
+// name, or nil.
+func LookupAggregate(name string) *AggregateFunction {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.aggregates[strings.ToLower(name)]
+}
+
+// RegisterSetReturningFunction adds a set-returning function to the function
+// registry, replacing any existing set-returning function of the same name.
+func RegisterSetReturningFunction(fn *SetReturningFunction) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.setReturning[strings.ToLower(fn.Name)] = fn
+}
+
+// LookupSetReturningFunction returns the registered set-returning function
+// with the given name, or nil.
+func LookupSetReturningFunction(name string) *SetReturningFunction {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.setReturning[strings.ToLower(name)]
+}
+
+// RegisterWindowFunction adds a window function to the function registry,
+// replacing any existing window function of the same name.
+func RegisterWindowFunction(fn *WindowFunction) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.windows[strings.ToLower(fn.Name)] = fn
+}
+
+// LookupWindowFunction returns the registered window function with the given
+// name, or nil.
+func LookupWindowFunction(name string) *WindowFunction {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.windows[strings.ToLower(name)]
+}
+
+// CheckArity returns an error if the function can't be called with n
+// arguments.
+func (fn *Function) CheckArity(n int) error {
+	return fn.checkArity(fn.Name, n)
+}
+
+// CheckArity returns an error if the function can't be called with n
+// arguments.
+func (fn *AggregateFunction) CheckArity(n int) error {
+	return fn.checkArity(fn.Name, n)
+}
+
+// CheckArity returns an error if the function can't be called with n
+// arguments.
+func (fn *SetReturningFunction) CheckArity(n int) error {
+	return fn.checkArity(fn.Name, n)
+}
+
+// CheckArity returns an error if the function can't be called with n
+// arguments.
+func (fn *WindowFunction) CheckArity(n int) error {
+	return fn.checkArity(fn.Name, n)
+}
+
+func (sig *Signature) checkArity(name string, n int) error {
+	if n < sig.MinArgs || (!sig.Variadic && n > len(sig.Args)) {
+		switch {
+		case sig.Variadic:
+			return fmt.Errorf("%v() expects at least %v arguments, got %v", name, sig.MinArgs, n)
+		case sig.MinArgs == len(sig.Args):
+			return fmt.Errorf("%v() expects %v arguments, got %v", name, sig.MinArgs, n)
+		}
+		return fmt.Errorf("%v() expects %v to %v arguments, got %v", name, sig.MinArgs, len(sig.Args), n)
+	}
+
+	return nil
+}
+
+// argType returns the declared type of the argument at index i.
+func (sig *Signature) argType(i int) Type {
+	if i >= len(sig.Args) {
+		return sig.Args[len(sig.Args)-1]
+	}
+	return sig.Args[i]
+}
+
+func argTypeMatches(declared, actual Type) bool {
+	switch {
+	case declared == AnyType, actual == AnyType, actual == NullType, declared == actual:
+		return true
+	case declared == FloatType && actual == IntegerType:
+		return true
+	case declared == TimestampType && actual == StringType:
+		return true
+	case declared == QuantityType && isQuantityOperand(actual):
+		return true
+	}
+	return false
+}
+
+func argTypeMismatch(name string, i int, declared, actual Type) error {
+	return fmt.Errorf("type mismatch: %v() argument %v expects %v, got %v", name, i+1, declared, actual)
+}
+
+func (sig *Signature) checkArgs(name string, args []interface{}) error {
+	for i, arg := range args {
+		declared, actual := sig.argType(i), TypeOf(arg)
+		if !argTypeMatches(declared, actual) {
+			return argTypeMismatch(name, i, declared, actual)
+		}
+	}
+
+	return nil
+}
+
+// convertArgs converts integers passed as float arguments, strings passed as
+// timestamp arguments, and strings and numbers passed as quantity arguments.
+func (sig *Signature) convertArgs(args []interface{}) error {
+	for i, arg := range args {
+		switch sig.argType(i) {
+		case FloatType:
+			if num, ok := toNumber(arg); ok {
+				args[i] = toFloat(num)
+			}
+
+		case TimestampType:
+			if str, ok := arg.(string); ok {
+				t, err := toTimestamp(str)
+				if err != nil {
+					return err
+				}
+				args[i] = t
+			}
+
+		case QuantityType:
+			if arg != nil {
+				q, err := toQuantity(arg)
+				if err != nil {
+					return err
+				}
+				args[i] = q
+			}
+		}
+	}
+
+	return nil
+}
+
+func (fn *Function) call(args []interface{}, programs *programCache, data map[string]interface{}) (val interface{}, err error) {
+	// errors don't give away the values of secret arguments
+	secrets := append([]interface{}(nil), args...)
+	defer func() {
+		err = redactError(err, secrets...)
+	}()
+
+	// a function of a secret returns a secret
+	secret := false
+	for i, arg := range args {
+		var revealed bool
+		args[i], revealed = revealSecret(arg)
+		secret = secret || revealed
+	}
+	if secret {
+		defer func() {
+			val = concealSecret(val, true)
+		}()
+	}
+
+	if !fn.CallOnNull {
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+		}
+	}
+
+	if err := fn.convertArgs(args); err != nil {
+		return nil, err
+	}
+	if fn.Now {
+		args = append(args, executionTime(data))
+	}
+
+	if fn.Compile != nil {
+		program, ok := args[fn.Program].(string)
+		if !ok {
+			return nil, nil
+		}
+
+		eval, err := programs.compile(fn, program)
+		if err != nil {
+			return nil, err
+		}
+		return eval(args)
+	}
+
+	return fn.Eval(args)
+}
+
+// ProgramLiteral returns the program argument of a call, if the function has
+// one and it's a string literal.
+func (expr *Call) ProgramLiteral() (*String, bool) {
+	if expr.Func.Compile == nil || expr.Func.Program >= len(expr.Args) {
+		return nil, false
+	}
+
+	literal, ok := expr.Args[expr.Func.Program].(*String)
+	return literal, ok
+}
+
+// Compile compiles the program of a call when it's a string literal, so that
+// it's ready for the first row and any error is reported before any data is
+// fetched.
+func (expr *Call) Compile() error {
+	literal, ok := expr.ProgramLiteral()
+	if !ok {
+		return nil
+	}
+
+	_, err := expr.programs.compile(expr.Func, literal.Val)
+	return err
+}
+
+// Columns returns the names of the columns of the call's rows.
+func (expr *SetReturningCall) Columns() []string {
+	columns := expr.Func.Columns
+	if expr.Ordinality {
+		columns = append(columns[:len(columns):len(columns)], "ordinality")
+	}
+	return columns
+}
+
+// ProgramLiteral returns the program argument of a call, if the function has
+// one and it's a string literal.
+func (expr *SetReturningCall) ProgramLiteral() (*String, bool) {
+	if expr.Func.Compile == nil || expr.Func.Program >= len(expr.Args) {
+		return nil, false
+	}
+
+	literal, ok := expr.Args[expr.Func.Program].(*String)
+	return literal, ok
+}
+
+// Compile compiles the program of a call when it's a string literal.
+func (expr *SetReturningCall) Compile() error {
+	literal, ok := expr.ProgramLiteral()
+	if !ok {
+		return nil
+	}
+
+	_, err := expr.programs.compile(expr.Func, literal.Val)
+	return err
+}
+
+// argError is an error caused by one of the arguments of a call, which is
+// reported at the argument's position in the query source.
+type argError struct {
+	index int
+	err   error
+}
+
+func (e *argError) Error() string {
+	return e.err.Error()
+}
+
+// programCache holds the compiled program of a call, which is reused for as
+// long as the program is unchanged.
+type programCache struct {
+	program string
+	eval    func(args []interface{}) (interface{}, error)
+}
+
+func (c *programCache) compile(fn *Function, program string) (func(args []interface{}) (interface{}, error), error) {
+	if c.eval == nil || c.program != program {
+		eval, err := fn.Compile(program)
+		if err != nil {
+			return nil, err
+		}
+		c.program, c.eval = program, eval
+	}
+
+	return c.eval, nil
+}
+
+// cacheKey returns a key identifying a set of scalar arguments, or false if
+// an argument isn't a scalar.
+func cacheKey(args []interface{}) (string, bool) {
+	var buf bytes.Buffer
+	for _, arg := range args {
+		switch arg.(type) {
+		case nil, bool, int, int64, float64, string:
+			fmt.Fprintf(&buf, "%T(%#v)", arg, arg)
+		default:
+			return "", false
+		}
+	}
+
+	return buf.String(), true
+}
+
+// setProgramCache holds the compiled program of a set-returning call.
+type setProgramCache struct {
+	program string
+	eval    func(args []interface{}) ([][]interface{}, error)
+}
+
+func (c *setProgramCache) compile(fn *SetReturningFunction, program string) (func(args []interface{}) ([][]interface{}, error), error) {
+	if c.eval == nil || c.program != program {
+		eval, err := fn.Compile(program)
+		if err != nil {
+			return nil, err
+		}
+		c.program, c.eval = program, eval
+	}
+
+	return c.eval, nil
+}
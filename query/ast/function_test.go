@@ -0,0 +1,50 @@
+package ast
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCallCache(t *testing.T) {
+	call := &Call{Name: "upper", Func: LookupFunction("upper"), Args: []Expr{&Reference{Name: "s"}}}
+
+	for i := 0; i < 2*maxCachedResults; i++ {
+		s := fmt.Sprintf("s%d", i)
+		val, err := call.Eval(map[string]interface{}{"s": s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val != fmt.Sprintf("S%d", i) {
+			t.Fatalf("upper(%q) = %#v", s, val)
+		}
+
+		if len(call.cache) > maxCachedResults {
+			t.Fatalf("%d results cached, expected at most %d", len(call.cache), maxCachedResults)
+		}
+	}
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			RegisterFunction(&Function{Name: fmt.Sprintf("test_concurrent_%d", i)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			if LookupFunction("upper") == nil {
+				t.Error("upper() isn't registered")
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		if LookupFunction(fmt.Sprintf("TEST_CONCURRENT_%d", i)) == nil {
+			t.Errorf("test_concurrent_%d() isn't registered", i)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/saracen/kubeql/query/joiner"
+	"github.com/saracen/kubeql/query/lexer"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Objects and arrays are the map[string]interface{} and []interface{} values
+// of resources. As with Postgres' jsonb, they're compared by value, and can be
+// tested for containment and the existence of keys.
+
+func toObject(val interface{}) (map[string]interface{}, bool) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return v, true
+	case joiner.Tuple:
+		return map[string]interface{}(v), true
+	}
+	return nil, false
+}
+
+// jsonEqual reports whether two values are deeply equal. Numbers are equal if
+// they have the same value, regardless of whether they're integers or floats.
+func jsonEqual(lhs, rhs interface{}) bool {
+	lhs, _ = revealSecret(lhs)
+	rhs, _ = revealSecret(rhs)
+
+	if l, ok := toObject(lhs); ok {
+		r, ok := toObject(rhs)
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for key, lval := range l {
+			rval, ok := r[key]
+			if !ok || !jsonEqual(lval, rval) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if l, ok := lhs.([]interface{}); ok {
+		r, ok := rhs.([]interface{})
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !jsonEqual(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if TypeOf(lhs).IsNumeric() && TypeOf(rhs).IsNumeric() {
+		equal, _ := numericOp(Operator(lexer.Equal), lhs, rhs)
+		return equal == true
+	}
+
+	switch l := lhs.(type) {
+	case nil, bool, string:
+		return lhs == rhs
+	case Duration, resource.Quantity, time.Time:
+		equal, err := op(l, Operator(lexer.Equal), rhs)
+		return err == nil && equal == true
+	}
+
+	return false
+}
+
+// DeepEqual reports whether two values are equal, comparing objects and
+// arrays by their elements. Unlike =, null is equal to null.
+func DeepEqual(lhs, rhs interface{}) bool {
+	return jsonEqual(lhs, rhs)
+}
+
+// jsonContains reports whether lhs contains rhs: objects contain objects
+// whose keys they have with containing values, arrays contain arrays whose
+// elements are each contained by one of their elements, and arrays contain
+// the scalars that are their elements. Other values contain equal values.
+func jsonContains(lhs, rhs interface{}) bool {
+	lhs, _ = revealSecret(lhs)
+	rhs, _ = revealSecret(rhs)
+
+	if l, ok := toObject(lhs); ok {
+		r, ok := toObject(rhs)
+		if !ok {
+			return false
+		}
+		for key, rval := range r {
+			lval, ok := l[key]
+			if !ok || !jsonContains(lval, rval) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if l, ok := lhs.([]interface{}); ok {
+		r, ok := rhs.([]interface{})
+		if !ok {
+			if _, isObject := toObject(rhs); isObject {
+				return false
+			}
+			r = []interface{}{rhs}
+		}
+
+	elements:
+		for _, rval := range r {
+			for _, lval := range l {
+				if jsonContains(lval, rval) {
+					continue elements
+				}
+			}
+			return false
+		}
+		return true
+	}
+
+	return jsonEqual(lhs, rhs)
+}
+
+// jsonHasKeys reports whether an object has a key (?), any of an array of
+// keys (?|) or all of them (?&). For arrays, keys are string elements.
+func jsonHasKeys(op Operator, lhs, rhs interface{}) (interface{}, error) {
+	has := func(key string) bool {
+		if obj, ok := toObject(lhs); ok {
+			_, ok := obj[key]
+			return ok
+		}
+		for _, elem := range lhs.([]interface{}) {
+			if elem, _ := revealSecret(elem); elem == key {
+				return true
+			}
+		}
+		return false
+	}
+
+	if lexer.TokenType(op) == lexer.HasKey {
+		return has(rhs.(string)), nil
+	}
+
+	keys := rhs.([]interface{})
+	for _, key := range keys {
+		key, _ = revealSecret(key)
+		str, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("type mismatch: %v expects an array of strings, got %v element", op, TypeOf(key))
+		}
+
+		if has(str) == (lexer.TokenType(op) == lexer.HasAnyKey) {
+			return lexer.TokenType(op) == lexer.HasAnyKey, nil
+		}
+	}
+
+	return lexer.TokenType(op) == lexer.HasAllKeys, nil
+}
+
+// jsonTypeOf returns the JSON type of a value.
+func jsonTypeOf(val interface{}) string {
+	switch t := TypeOf(val); t {
+	case IntegerType, FloatType:
+		return "number"
+	case TimestampType, IntervalType, QuantityType:
+		return "string"
+	case AnyType:
+		return "unknown"
+	default:
+		return t.String()
+	}
+}
+
+// jsonKeys returns the sorted keys of an object.
+func jsonKeys(obj map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]interface{}, len(keys))
+	for i, key := range keys {
+		result[i] = key
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/saracen/kubeql/query/lexer"
+)
+
+// Numbers are either integers, represented as int64, or floats, represented
+// as float64. Integer literals and integral JSON numbers are int64, so values
+// from a query and values from resources compare the same way regardless of
+// which side of an operator they appear on.
+//
+// When an operator is applied to an integer and a float, the integer is
+// promoted to a float. Operations on two integers stay integers: division
+// truncates towards zero, and results that overflow are an error rather than
+// wrapping around.
+
+var (
+	errDivisionByZero  = fmt.Errorf("division by zero")
+	errIntegerOverflow = fmt.Errorf("integer out of range")
+	errFloatOverflow   = fmt.Errorf("float out of range")
+)
+
+// toNumber normalizes a numeric value to int64 or float64.
+func toNumber(val interface{}) (interface{}, bool) {
+	switch v := val.(type) {
+	case int64, float64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case float32:
+		return float64(v), true
+	}
+	return nil, false
+}
+
+func toFloat(val interface{}) float64 {
+	if i, ok := val.(int64); ok {
+		return float64(i)
+	}
+	return val.(float64)
+}
+
+// numericOp applies an arithmetic or comparison operator to two numbers.
+func numericOp(op Operator, lhs, rhs interface{}) (interface{}, error) {
+	l, ok := toNumber(lhs)
+	if !ok {
+		return nil, typeMismatch(op, TypeOf(lhs), TypeOf(rhs))
+	}
+	r, ok := toNumber(rhs)
+	if !ok {
+		return nil, typeMismatch(op, TypeOf(lhs), TypeOf(rhs))
+	}
+
+	li, lok := l.(int64)
+	ri, rok := r.(int64)
+	if lok && rok {
+		return integerOp(op, li, ri)
+	}
+
+	return floatOp(op, toFloat(l), toFloat(r))
+}
+
+func integerOp(op Operator, lhs, rhs int64) (interface{}, error) {
+	switch lexer.TokenType(op) {
+	case lexer.Equal:
+		return lhs == rhs, nil
+	case lexer.NotEqual:
+		return lhs != rhs, nil
+	case lexer.LessThan:
+		return lhs < rhs, nil
+	case lexer.LessThanEqual:
+		return lhs <= rhs, nil
+	case lexer.GreaterThan:
+		return lhs > rhs, nil
+	case lexer.GreaterThanEqual:
+		return lhs >= rhs, nil
+
+	case lexer.Add:
+		if (rhs > 0 && lhs > math.MaxInt64-rhs) || (rhs < 0 && lhs < math.MinInt64-rhs) {
+			return nil, errIntegerOverflow
+		}
+		return lhs + rhs, nil
+
+	case lexer.Subtract:
+		if (rhs < 0 && lhs > math.MaxInt64+rhs) || (rhs > 0 && lhs < math.MinInt64+rhs) {
+			return nil, errIntegerOverflow
+		}
+		return lhs - rhs, nil
+
+	case lexer.Multiply:
+		if lhs == 0 || rhs == 0 {
+			return int64(0), nil
+		}
+		result := lhs * rhs
+		if result/rhs != lhs || (lhs == -1 && rhs == math.MinInt64) || (rhs == -1 && lhs == math.MinInt64) {
+			return nil, errIntegerOverflow
+		}
+		return result, nil
+
+	case lexer.Divide:
+		if rhs == 0 {
+			return nil, errDivisionByZero
+		}
+		if lhs == math.MinInt64 && rhs == -1 {
+			return nil, errIntegerOverflow
+		}
+		return lhs / rhs, nil
+
+	case lexer.Modulo:
+		if rhs == 0 {
+			return nil, errDivisionByZero
+		}
+		if rhs == -1 {
+			return int64(0), nil
+		}
+		return lhs % rhs, nil
+	}
+
+	return nil, typeMismatch(op, IntegerType, IntegerType)
+}
+
+func floatOp(op Operator, lhs, rhs float64) (interface{}, error) {
+	var result float64
+
+	switch lexer.TokenType(op) {
+	case lexer.Equal:
+		return lhs == rhs, nil
+	case lexer.NotEqual:
+		return lhs != rhs, nil
+	case lexer.LessThan:
+		return lhs < rhs, nil
+	case lexer.LessThanEqual:
+		return lhs <= rhs, nil
+	case lexer.GreaterThan:
+		return lhs > rhs, nil
+	case lexer.GreaterThanEqual:
+		return lhs >= rhs, nil
+
+	case lexer.Add:
+		result = lhs + rhs
+	case lexer.Subtract:
+		result = lhs - rhs
+	case lexer.Multiply:
+		result = lhs * rhs
+	case lexer.Divide:
+		if rhs == 0 {
+			return nil, errDivisionByZero
+		}
+		result = lhs / rhs
+	case lexer.Modulo:
+		if rhs == 0 {
+			return nil, errDivisionByZero
+		}
+		result = math.Mod(lhs, rhs)
+
+	default:
+		return nil, typeMismatch(op, FloatType, FloatType)
+	}
+
+	if math.IsInf(result, 0) && !math.IsInf(lhs, 0) && !math.IsInf(rhs, 0) {
+		return nil, errFloatOverflow
+	}
+
+	return result, nil
+}
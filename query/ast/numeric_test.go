@@ -0,0 +1,134 @@
+package ast
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/saracen/kubeql/query/lexer"
+)
+
+func TestNumericOperators(t *testing.T) {
+	tests := []struct {
+		lhs      interface{}
+		op       lexer.TokenType
+		rhs      interface{}
+		expected interface{}
+		err      string
+	}{
+		// integers stay integers
+		{int64(1), lexer.Add, int64(2), int64(3), ""},
+		{int64(7), lexer.Divide, int64(2), int64(3), ""},
+		{int64(-7), lexer.Divide, int64(2), int64(-3), ""},
+		{int64(-7), lexer.Modulo, int64(2), int64(-1), ""},
+		{int64(3), lexer.Multiply, int64(-4), int64(-12), ""},
+
+		// other integer types, as registered functions may return, are
+		// integers too
+		{int(2), lexer.Multiply, int32(3), int64(6), ""},
+		{uint8(2), lexer.Equal, int64(2), true, ""},
+
+		// an integer and a float is a float, whichever side it's on
+		{int64(1), lexer.Add, 1.5, 2.5, ""},
+		{1.5, lexer.Add, int64(1), 2.5, ""},
+		{int64(7), lexer.Divide, 2.0, 3.5, ""},
+		{float32(0.5), lexer.Multiply, int64(3), 1.5, ""},
+		{int64(1), lexer.Equal, 1.0, true, ""},
+		{int64(2), lexer.LessThan, 2.5, true, ""},
+		{3.0, lexer.GreaterThanEqual, int64(3), true, ""},
+
+		// overflow is an error rather than wrapping around
+		{int64(math.MaxInt64), lexer.Add, int64(1), nil, "integer out of range"},
+		{int64(math.MinInt64), lexer.Subtract, int64(1), nil, "integer out of range"},
+		{int64(math.MaxInt64), lexer.Multiply, int64(2), nil, "integer out of range"},
+		{int64(math.MinInt64), lexer.Multiply, int64(-1), nil, "integer out of range"},
+		{int64(math.MinInt64), lexer.Divide, int64(-1), nil, "integer out of range"},
+		{int64(math.MinInt64), lexer.Modulo, int64(-1), int64(0), ""},
+		{int64(math.MaxInt64), lexer.Add, 1.0, float64(math.MaxInt64) + 1, ""},
+		{math.MaxFloat64, lexer.Multiply, int64(10), nil, "float out of range"},
+
+		{int64(1), lexer.Divide, int64(0), nil, "division by zero"},
+		{int64(1), lexer.Modulo, int64(0), nil, "division by zero"},
+		{1.0, lexer.Divide, int64(0), nil, "division by zero"},
+
+		{"a", lexer.Add, int64(1), nil, "type mismatch: string + integer"},
+		{int64(1), lexer.Subtract, true, nil, "type mismatch: integer - boolean"},
+
+		// null is null whatever the other operand
+		{nil, lexer.Add, int64(1), nil, ""},
+		{1.5, lexer.Multiply, nil, nil, ""},
+	}
+
+	for _, test := range tests {
+		expr := &BinaryExpr{
+			Op:  Operator(test.op),
+			LHS: &Reference{Name: "lhs"},
+			RHS: &Reference{Name: "rhs"},
+		}
+
+		val, err := expr.Eval(map[string]interface{}{"lhs": test.lhs, "rhs": test.rhs})
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%#v %v %#v: got error %v, expected %q", test.lhs, expr.Op, test.rhs, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%#v %v %#v: %v", test.lhs, expr.Op, test.rhs, err)
+			continue
+		}
+		if val != test.expected {
+			t.Errorf("%#v %v %#v = %#v, expected %#v", test.lhs, expr.Op, test.rhs, val, test.expected)
+		}
+	}
+}
+
+func TestNegate(t *testing.T) {
+	tests := []struct {
+		val      interface{}
+		expected interface{}
+		err      string
+	}{
+		{int64(1), int64(-1), ""},
+		{int32(-2), int64(2), ""},
+		{1.5, -1.5, ""},
+		{int64(math.MinInt64), nil, "integer out of range"},
+		{"a", nil, "type mismatch: - string"},
+		{nil, nil, ""},
+	}
+
+	for _, test := range tests {
+		expr := &UnaryExpr{Op: Operator(lexer.Subtract), Expr: &Reference{Name: "val"}}
+
+		val, err := expr.Eval(map[string]interface{}{"val": test.val})
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("-%#v: got error %v, expected %q", test.val, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("-%#v: %v", test.val, err)
+			continue
+		}
+		if val != test.expected {
+			t.Errorf("-%#v = %#v, expected %#v", test.val, val, test.expected)
+		}
+	}
+}
+
+func TestNumericPermissive(t *testing.T) {
+	expr := &BinaryExpr{
+		Op:         Operator(lexer.Add),
+		LHS:        &String{Val: "a"},
+		RHS:        &Integer{Val: 1},
+		Permissive: true,
+	}
+
+	val, err := expr.Eval(nil)
+	if err != nil || val != nil {
+		t.Errorf("'a' + 1 = %#v, %v, expected null when permissive", val, err)
+	}
+}
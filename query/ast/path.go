@@ -0,0 +1,221 @@
+package ast
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/saracen/kubeql/query/joiner"
+)
+
+// matchPathExpression follows a path through objects and arrays. A missing key
+// or index is null, unless the path has a wildcard, recursion or slice, which
+// evaluates to an array of the values the path reaches, leaving out those
+// that are missing.
+func matchPathExpression(content interface{}, steps []*PathStep, data map[string]interface{}) (interface{}, error) {
+	for _, step := range steps {
+		switch step.Kind {
+		case PathWildcard, PathRecursive, PathSlice:
+			values := []interface{}{}
+			if err := collectPath(content, steps, data, false, &values); err != nil {
+				return nil, err
+			}
+			return values, nil
+		}
+	}
+
+	return matchPath(content, steps, data)
+}
+
+func matchPath(content interface{}, steps []*PathStep, data map[string]interface{}) (val interface{}, err error) {
+	if len(steps) == 0 {
+		return content, nil
+	}
+
+	content, secret := revealSecret(content)
+	if secret {
+		defer func() {
+			val = concealSecret(val, true)
+		}()
+	}
+
+	key, ok, err := pathKey(steps[0], data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch content.(type) {
+	case joiner.Tuple, map[string]interface{}, []interface{}:
+		if !ok {
+			return nil, nil
+		}
+		child, ok := pathChild(content, key)
+		if !ok {
+			return nil, nil
+		}
+		return matchPath(child, steps[1:], data)
+	}
+
+	return content, nil
+}
+
+// collectPath appends the values reached by a path to out.
+func collectPath(content interface{}, steps []*PathStep, data map[string]interface{}, secret bool, out *[]interface{}) error {
+	content, revealed := revealSecret(content)
+	secret = secret || revealed
+
+	if len(steps) == 0 {
+		*out = append(*out, concealSecret(content, secret))
+		return nil
+	}
+
+	switch step := steps[0]; step.Kind {
+	case PathWildcard:
+		for _, elem := range pathElements(content) {
+			if err := collectPath(elem, steps[1:], data, secret, out); err != nil {
+				return err
+			}
+		}
+
+	case PathRecursive:
+		if err := collectPath(content, steps[1:], data, secret, out); err != nil {
+			return err
+		}
+		for _, elem := range pathElements(content) {
+			if err := collectPath(elem, steps, data, secret, out); err != nil {
+				return err
+			}
+		}
+
+	case PathSlice:
+		arr, ok := content.([]interface{})
+		if !ok {
+			return nil
+		}
+
+		start, end := sliceBounds(len(arr), step.Start, step.End)
+		for _, elem := range arr[start:end] {
+			if err := collectPath(elem, steps[1:], data, secret, out); err != nil {
+				return err
+			}
+		}
+
+	default:
+		key, ok, err := pathKey(step, data)
+		if err != nil || !ok {
+			return err
+		}
+
+		child, ok := pathChild(content, key)
+		if !ok {
+			return nil
+		}
+		return collectPath(child, steps[1:], data, secret, out)
+	}
+
+	return nil
+}
+
+// pathKey returns the key of a step. Computed integers are array indexes, and
+// a computed value that isn't a string or integer is never found.
+func pathKey(step *PathStep, data map[string]interface{}) (string, bool, error) {
+	if step.Kind != PathComputed {
+		return step.Key, true, nil
+	}
+
+	evaled, err := step.Expr.Eval(data)
+	if err != nil {
+		return "", false, err
+	}
+
+	evaled, _ = revealSecret(evaled)
+	switch v := evaled.(type) {
+	case string:
+		return v, true, nil
+	case int64:
+		return strconv.FormatInt(v, 10), true, nil
+	}
+
+	return "", false, nil
+}
+
+// pathChild returns the value of an object's key, or an array's index, which
+// counts back from the end when negative.
+func pathChild(content interface{}, key string) (interface{}, bool) {
+	switch v := content.(type) {
+	case joiner.Tuple:
+		child, ok := v[key]
+		return child, ok
+
+	case map[string]interface{}:
+		child, ok := v[key]
+		return child, ok
+
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, false
+		}
+		if i < 0 {
+			i += len(v)
+		}
+
+		if i >= 0 && i < len(v) {
+			return v[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// pathElements returns the elements of an array, or the values of an object
+// sorted by key.
+func pathElements(content interface{}) []interface{} {
+	if arr, ok := content.([]interface{}); ok {
+		return arr
+	}
+
+	obj, ok := toObject(content)
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	elems := make([]interface{}, len(keys))
+	for i, key := range keys {
+		elems[i] = obj[key]
+	}
+	return elems
+}
+
+// sliceBounds returns the bounds of a slice of an array of length n. Negative
+// bounds count back from the end, and bounds beyond the array are clamped.
+func sliceBounds(n int, start, end *int) (int, int) {
+	bound := func(b *int, def int) int {
+		if b == nil {
+			return def
+		}
+
+		i := *b
+		if i < 0 {
+			i += n
+		}
+		switch {
+		case i < 0:
+			return 0
+		case i > n:
+			return n
+		}
+		return i
+	}
+
+	from, to := bound(start, 0), bound(end, n)
+	if from > to {
+		return from, from
+	}
+	return from, to
+}
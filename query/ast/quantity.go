@@ -0,0 +1,166 @@
+package ast
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/saracen/kubeql/query/lexer"
+	"gopkg.in/inf.v0"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Quantities are represented as resource.Quantity, the type Kubernetes uses
+// for resource requests and limits such as "500m" or "1Gi". Strings and
+// numbers are parsed as quantities wherever they're combined with a quantity.
+
+// toQuantity converts a quantity, a quantity string or a number to a
+// quantity.
+func toQuantity(val interface{}) (resource.Quantity, error) {
+	switch v := val.(type) {
+	case resource.Quantity:
+		return v, nil
+	case string:
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return resource.Quantity{}, fmt.Errorf("invalid quantity %q", v)
+		}
+		return q, nil
+	}
+
+	if num, ok := toNumber(val); ok {
+		if i, ok := num.(int64); ok {
+			return *resource.NewQuantity(i, resource.DecimalSI), nil
+		}
+		return toQuantity(strconv.FormatFloat(num.(float64), 'f', -1, 64))
+	}
+
+	return resource.Quantity{}, fmt.Errorf("type mismatch: expected quantity, got %v", TypeOf(val))
+}
+
+// quantityFloat returns the approximate value of a quantity as a float.
+func quantityFloat(q resource.Quantity) float64 {
+	q = q.DeepCopy()
+	f, _ := strconv.ParseFloat(q.AsDec().String(), 64)
+	return f
+}
+
+func isQuantityOperand(t Type) bool {
+	return isType(t, QuantityType, StringType, IntegerType, FloatType)
+}
+
+// quantityOperatorType returns the result type of an operator applied to a
+// quantity, and whether the operand types are compatible.
+func quantityOperatorType(op Operator, lhs, rhs Type) (Type, bool) {
+	switch lexer.TokenType(op) {
+	case lexer.Equal, lexer.NotEqual, lexer.LessThan, lexer.LessThanEqual,
+		lexer.GreaterThan, lexer.GreaterThanEqual:
+		return BooleanType, isQuantityOperand(lhs) && isQuantityOperand(rhs)
+
+	case lexer.Add, lexer.Subtract:
+		return QuantityType, isQuantityOperand(lhs) && isQuantityOperand(rhs)
+
+	case lexer.Multiply:
+		return QuantityType, (lhs == QuantityType && isType(rhs, IntegerType, FloatType)) ||
+			(isType(lhs, IntegerType, FloatType) && rhs == QuantityType)
+
+	case lexer.Divide:
+		// dividing by a number scales a quantity, and dividing by another
+		// quantity is a ratio
+		switch {
+		case lhs == QuantityType && rhs == AnyType:
+			return AnyType, true
+		case lhs == QuantityType && rhs.IsNumeric():
+			return QuantityType, true
+		case isQuantityOperand(lhs) && isType(rhs, QuantityType, StringType):
+			return FloatType, true
+		}
+	}
+
+	return AnyType, false
+}
+
+// quantityOp applies an operator to a quantity.
+func quantityOp(op Operator, lhs, rhs interface{}) (interface{}, error) {
+	lt, rt := TypeOf(lhs), TypeOf(rhs)
+
+	switch lexer.TokenType(op) {
+	case lexer.Multiply:
+		if lt == QuantityType && rt.IsNumeric() {
+			return scaleQuantity(lhs.(resource.Quantity), rhs, false)
+		}
+		if lt.IsNumeric() && rt == QuantityType {
+			return scaleQuantity(rhs.(resource.Quantity), lhs, false)
+		}
+		return nil, typeMismatch(op, lt, rt)
+
+	case lexer.Divide:
+		if lt == QuantityType && rt.IsNumeric() {
+			return scaleQuantity(lhs.(resource.Quantity), rhs, true)
+		}
+	}
+
+	l, err := toQuantity(lhs)
+	if err != nil {
+		return nil, err
+	}
+	r, err := toQuantity(rhs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch lexer.TokenType(op) {
+	case lexer.Add:
+		result := l.DeepCopy()
+		result.Add(r)
+		return result, nil
+
+	case lexer.Subtract:
+		result := l.DeepCopy()
+		result.Sub(r)
+		return result, nil
+
+	case lexer.Divide:
+		if r.IsZero() {
+			return nil, errDivisionByZero
+		}
+		return quantityFloat(l) / quantityFloat(r), nil
+	}
+
+	if val, ok := compareOp(op, int64(l.Cmp(r))); ok {
+		return val, nil
+	}
+
+	return nil, typeMismatch(op, lt, rt)
+}
+
+// scaleQuantity multiplies, or divides, a quantity by a number, keeping the
+// quantity's format.
+func scaleQuantity(q resource.Quantity, num interface{}, divide bool) (interface{}, error) {
+	n, _ := toNumber(num)
+
+	factor, ok := new(inf.Dec).SetString(strconv.FormatFloat(toFloat(n), 'f', -1, 64))
+	if !ok {
+		return nil, fmt.Errorf("invalid quantity factor %v", num)
+	}
+
+	result := q.DeepCopy()
+	dec := result.AsDec()
+	if divide {
+		if factor.Sign() == 0 {
+			return nil, errDivisionByZero
+		}
+		dec = new(inf.Dec).QuoRound(dec, factor, 9, inf.RoundHalfUp)
+	} else {
+		dec = new(inf.Dec).Mul(dec, factor)
+	}
+
+	// the difference is added to the original quantity, rather than the
+	// result being parsed on its own, so that its format is kept
+	diff, err := resource.ParseQuantity(new(inf.Dec).Sub(dec, result.AsDec()).String())
+	if err != nil {
+		return nil, errFloatOverflow
+	}
+	result.Add(diff)
+
+	return result, nil
+}
@@ -0,0 +1,172 @@
+package ast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Redacted replaces secret values in output.
+const Redacted = "REDACTED"
+
+// Secret is a value from the data of a Kubernetes secret. Values derived from
+// a secret, by operators and functions, are secrets too, so that they're
+// redacted wherever they end up in the output.
+type Secret struct {
+	Val interface{}
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Redacted)
+}
+
+func (s Secret) String() string {
+	return Redacted
+}
+
+// Format redacts the secret whatever the verb, so that %#v and the like don't
+// print its value either.
+func (s Secret) Format(f fmt.State, verb rune) {
+	io.WriteString(f, Redacted)
+}
+
+// revealSecret returns the value of a secret, and whether it was a secret.
+// Other values are returned unchanged.
+func revealSecret(val interface{}) (interface{}, bool) {
+	if s, ok := val.(Secret); ok {
+		return s.Val, true
+	}
+	return val, false
+}
+
+// concealSecret wraps a value derived from a secret as a secret. Null is
+// never a secret.
+func concealSecret(val interface{}, secret bool) interface{} {
+	if !secret || val == nil {
+		return val
+	}
+	if _, ok := val.(Secret); ok {
+		return val
+	}
+	return Secret{Val: val}
+}
+
+// revealSecrets returns a copy of a value with the secrets in any objects and
+// arrays it contains revealed, and whether it contained a secret. Values
+// without secrets are returned unchanged.
+func revealSecrets(val interface{}) (interface{}, bool) {
+	val, secret := revealSecret(val)
+
+	switch v := val.(type) {
+	case map[string]interface{}:
+		var result map[string]interface{}
+		for key, elem := range v {
+			if revealed, ok := revealSecrets(elem); ok {
+				if result == nil {
+					result = make(map[string]interface{}, len(v))
+					for key, elem := range v {
+						result[key] = elem
+					}
+				}
+				result[key] = revealed
+			}
+		}
+		if result != nil {
+			return result, true
+		}
+
+	case []interface{}:
+		var result []interface{}
+		for i, elem := range v {
+			if revealed, ok := revealSecrets(elem); ok {
+				if result == nil {
+					result = append([]interface{}(nil), v...)
+				}
+				result[i] = revealed
+			}
+		}
+		if result != nil {
+			return result, true
+		}
+	}
+
+	return val, secret
+}
+
+// redactError replaces the values of the secrets in vals, wherever they're
+// nested, in the message of an error, as errors often quote the value they
+// couldn't handle.
+func redactError(err error, vals ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	var texts []string
+	for _, val := range vals {
+		texts = secretTexts(val, false, texts)
+	}
+	if len(texts) == 0 {
+		return err
+	}
+
+	// the longest are replaced first, so that a secret containing another is
+	// redacted whole
+	sort.Slice(texts, func(i, j int) bool {
+		return len(texts[i]) > len(texts[j])
+	})
+
+	redact := func(msg string) string {
+		for _, text := range texts {
+			msg = strings.Replace(msg, text, Redacted, -1)
+		}
+		return msg
+	}
+
+	switch e := err.(type) {
+	case *EvalError:
+		return &EvalError{Offset: e.Offset, Msg: redact(e.Msg)}
+	case *argError:
+		return &argError{index: e.index, err: errors.New(redact(e.err.Error()))}
+	}
+	return errors.New(redact(err.Error()))
+}
+
+// secretTexts appends the text of the secret values in a value to texts, both
+// as they're printed and as they're quoted.
+func secretTexts(val interface{}, secret bool, texts []string) []string {
+	if s, ok := val.(Secret); ok {
+		val, secret = s.Val, true
+	}
+
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for _, elem := range v {
+			texts = secretTexts(elem, secret, texts)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			texts = secretTexts(elem, secret, texts)
+		}
+	case nil:
+	default:
+		if !secret {
+			break
+		}
+
+		text := fmt.Sprint(v)
+		if text == "" {
+			break
+		}
+		texts = append(texts, text)
+
+		if quoted := strconv.Quote(text); quoted[1:len(quoted)-1] != text {
+			texts = append(texts, quoted[1:len(quoted)-1])
+		}
+	}
+
+	return texts
+}
@@ -0,0 +1,225 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saracen/kubeql/query/lexer"
+)
+
+func TestSecretFormatting(t *testing.T) {
+	secret := Secret{Val: "hunter2"}
+
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%d"} {
+		if out := fmt.Sprintf(verb, secret); strings.Contains(out, "hunter2") || !strings.Contains(out, Redacted) {
+			t.Errorf("%v of a secret is %q", verb, out)
+		}
+	}
+
+	nested := map[string]interface{}{"password": secret}
+	if out := fmt.Sprintf("%v %+v %#v", nested, nested, nested); strings.Contains(out, "hunter2") {
+		t.Errorf("secret within an object is formatted as %q", out)
+	}
+
+	if out, err := json.Marshal(nested); err != nil || string(out) != `{"password":"REDACTED"}` {
+		t.Errorf("secret within an object is marshalled as %s, %v", out, err)
+	}
+}
+
+func TestSecretPropagation(t *testing.T) {
+	secret := Secret{Val: "hunter2"}
+	data := map[string]interface{}{
+		"secret": secret,
+		"data":   map[string]interface{}{"password": secret, "username": "admin"},
+		"list":   []interface{}{"a", secret},
+		"plain":  "admin",
+	}
+
+	call := func(name string, args ...Expr) Expr {
+		return &Call{Name: name, Func: LookupFunction(name), Args: args}
+	}
+	ref := func(name string) Expr {
+		return &Reference{Name: name}
+	}
+
+	tests := []struct {
+		name     string
+		expr     Expr
+		revealed interface{}
+	}{
+		{"format of a secret", call("format", &String{Val: "%v!"}, ref("secret")), "hunter2!"},
+		{"format of an object with a secret", call("format", &String{Val: "%v"}, ref("data")), "map[password:hunter2 username:admin]"},
+		{"format of an array with a secret", call("format", &String{Val: "%v"}, ref("list")), "[a hunter2]"},
+		{"concat()", call("concat", &String{Val: "pw="}, ref("secret")), "pw=hunter2"},
+		{"||", &BinaryExpr{Op: Operator(lexer.Concat), LHS: &String{Val: "pw="}, RHS: ref("secret")}, "pw=hunter2"},
+		{"upper()", call("upper", ref("secret")), "HUNTER2"},
+		{"comparison", &BinaryExpr{Op: Operator(lexer.Equal), LHS: ref("secret"), RHS: &String{Val: "hunter2"}}, true},
+		{"cel()", call("cel", ref("data"), &String{Val: "self.password"}), "hunter2"},
+		{"cel() of another field", call("cel", ref("data"), &String{Val: "self.username"}), "admin"},
+	}
+
+	for _, test := range tests {
+		val, err := test.expr.Eval(data)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		revealed, ok := revealSecret(val)
+		if !ok {
+			t.Errorf("%v: %#v isn't a secret", test.name, val)
+			continue
+		}
+		if revealed != test.revealed {
+			t.Errorf("%v: secret is %#v, expected %#v", test.name, revealed, test.revealed)
+		}
+	}
+
+	// values not derived from a secret aren't secrets
+	val, err := call("format", &String{Val: "%v"}, ref("plain")).Eval(data)
+	if err != nil || val != "admin" {
+		t.Errorf("format of a non-secret is %#v, %v", val, err)
+	}
+}
+
+func TestSecretAggregates(t *testing.T) {
+	expr := &AggregateCall{Name: "max", Func: LookupAggregate("max"), Args: []Expr{&Reference{Name: "v"}}}
+
+	expr.Reset()
+	for _, val := range []interface{}{int64(1), Secret{Val: int64(3)}, int64(2)} {
+		if err := expr.Step(map[string]interface{}{"v": val}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	val, err := expr.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revealed, ok := revealSecret(val); !ok || revealed != int64(3) {
+		t.Errorf("max of rows with a secret is %#v, expected a secret 3", val)
+	}
+
+	// a new set of rows starts without secrets
+	expr.Reset()
+	if err := expr.Step(map[string]interface{}{"v": int64(1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err = expr.Eval(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != int64(1) {
+		t.Errorf("max after Reset is %#v, expected 1", val)
+	}
+}
+
+func TestSecretWindows(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"i": int64(1), "v": int64(1)},
+		{"i": int64(2), "v": Secret{Val: int64(2)}},
+		{"i": int64(3), "v": int64(3)},
+	}
+	window := &Window{OrderBy: []*OrderTerm{{Expr: &Reference{Name: "i"}}}}
+
+	tests := []struct {
+		name     string
+		expr     *WindowCall
+		expected []interface{}
+	}{
+		{
+			// a running sum is secret from the secret row on
+			name:     "sum",
+			expr:     &WindowCall{Name: "sum", Aggregate: LookupAggregate("sum"), Window: window},
+			expected: []interface{}{int64(1), Secret{Val: int64(3)}, Secret{Val: int64(6)}},
+		},
+		{
+			name:     "lag",
+			expr:     &WindowCall{Name: "lag", Func: LookupWindowFunction("lag"), Window: window},
+			expected: []interface{}{nil, int64(1), Secret{Val: int64(2)}},
+		},
+		{
+			name:     "row_number",
+			expr:     &WindowCall{Name: "row_number", Func: LookupWindowFunction("row_number"), Window: window},
+			expected: []interface{}{int64(1), int64(2), int64(3)},
+		},
+	}
+
+	for _, test := range tests {
+		if test.expr.Func == nil || len(test.expr.Func.Signature.Args) > 0 {
+			test.expr.Args = []Expr{&Reference{Name: "v"}}
+		}
+
+		if err := test.expr.Evaluate(rows); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		for i, expected := range test.expected {
+			if val := test.expr.Results[i]; val != expected {
+				t.Errorf("%v: row %d is %#v, expected %#v", test.name, i, revealedString(val), revealedString(expected))
+			}
+		}
+	}
+}
+
+func TestSecretErrors(t *testing.T) {
+	call := func(name string, args ...Expr) Expr {
+		return &Call{Name: name, Func: LookupFunction(name), Args: args}
+	}
+	ref := func(name string) Expr {
+		return &Reference{Name: name}
+	}
+	ts := &Timestamp{Val: time.Now()}
+
+	tests := []struct {
+		name   string
+		expr   Expr
+		secret string
+	}{
+		{"age()", call("age", ref("secret")), "hunter2"},
+		{"comparison with a timestamp", &BinaryExpr{Op: Operator(lexer.GreaterThan), LHS: ref("secret"), RHS: ts}, "hunter2"},
+		{"adding an interval", &BinaryExpr{Op: Operator(lexer.Add), LHS: ref("secret"), RHS: &Interval{Val: Duration(time.Hour)}}, "hunter2"},
+		{"quantity()", call("quantity", ref("secret")), "hunter2"},
+		{"matches_selector()", call("matches_selector", ref("labels"), ref("secret")), "hunter2 <"},
+		{"image_tag() of an invalid digest", call("image_tag", ref("secret")), "nginx@hunter2"},
+		{"image_tag() of an invalid tag", call("image_tag", ref("secret")), "nginx:hunter2!"},
+		{"image_tag() of an invalid repository", call("image_tag", ref("secret")), "hunter2/Nginx"},
+		{"date_trunc()", call("date_trunc", ref("secret"), ts), "hunter2"},
+		{"extract() of a timestamp", call("extract", ref("secret"), ts), "hunter2"},
+		{"extract() of an interval", call("extract", ref("secret"), &Interval{Val: Duration(time.Hour)}), "hunter2"},
+		{"json_set() of an array", call("json_set", ref("list"), ref("secret"), &Integer{Val: 1}), "hunter2"},
+		{"json_set() of a string", call("json_set", &String{Val: "a"}, ref("secret"), &Integer{Val: 1}), "hunter2"},
+		{"regular expression", &MatchExpr{Op: Operator(lexer.Match), Expr: &String{Val: "a"}, Pattern: ref("secret")}, "hunter2("},
+		{"quoted", call("age", ref("secret")), "hunter2\n\"\""},
+	}
+
+	for _, test := range tests {
+		data := map[string]interface{}{
+			"secret": Secret{Val: test.secret},
+			"labels": map[string]interface{}{"app": "web"},
+			"list":   []interface{}{"a"},
+		}
+
+		_, err := test.expr.Eval(data)
+		if err == nil {
+			t.Errorf("%v: expected an error", test.name)
+			continue
+		}
+		if msg := err.Error(); strings.Contains(msg, "hunter2") {
+			t.Errorf("%v: error is %q", test.name, msg)
+		}
+	}
+}
+
+// revealedString describes a value, including the value of a secret.
+func revealedString(val interface{}) string {
+	if revealed, ok := revealSecret(val); ok {
+		return fmt.Sprintf("secret %#v", revealed)
+	}
+	return fmt.Sprintf("%#v", val)
+}
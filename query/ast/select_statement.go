@@ -1,9 +1,63 @@
 package ast
 
 type SelectStatement struct {
+	With         *WithClause
 	SelectClause *SelectClause
 	FromClause   *FromClause
 	WhereClause  *WhereClause
+
+	// Compound is set, instead of the clauses, for statements combined by a
+	// set operation
+	Compound *CompoundStatement
+
+	Limit *LimitClause
+}
+
+// LimitClause is the maximum number of rows of a statement, Count or the
+// value of Param.
+type LimitClause struct {
+	Count int64
+	Param *Parameter
+}
+
+// CompoundStatement combines the rows of two statements with UNION,
+// INTERSECT or EXCEPT. Unless All is set, duplicate rows are removed.
+type CompoundStatement struct {
+	Op          Operator
+	All         bool
+	Left, Right *SelectStatement
+}
+
+// Walk walks the select clause expressions of each statement of a compound
+// statement.
+func (stmt *CompoundStatement) Walk(v Visitor) Expr {
+	for _, s := range []*SelectStatement{stmt.Left, stmt.Right} {
+		if s.Compound != nil {
+			s.Compound.Walk(v)
+			continue
+		}
+		s.SelectClause.Walk(v)
+	}
+	return nil
+}
+
+// WithClause names statements, common table expressions, that are evaluated
+// once and referenced like resources.
+type WithClause struct {
+	Recursive bool
+	Tables    []*CommonTableExpr
+}
+
+// CommonTableExpr is a named statement of a WITH clause. A recursive
+// statement is evaluated repeatedly against the rows it last returned, and
+// its rows are added to those of the initial Select until it returns no new
+// rows.
+type CommonTableExpr struct {
+	Name      string
+	Select    *SelectStatement
+	Recursive *SelectStatement
+	// UnionAll keeps duplicate rows
+	UnionAll bool
 }
 
 type SelectClause struct {
@@ -12,7 +66,9 @@ type SelectClause struct {
 
 func (stmt *SelectClause) Walk(v Visitor) Expr {
 	for _, expression := range stmt.Expressions {
-		expression.Condition.Walk(v)
+		if expression.Condition != nil {
+			expression.Condition.Walk(v)
+		}
 	}
 	return nil
 }
@@ -20,15 +76,72 @@ func (stmt *SelectClause) Walk(v Visitor) Expr {
 type SelectExpression struct {
 	Alias     string
 	Condition Expr
+
+	// Star is set, instead of Condition, for * and alias.*
+	Star *Star
+}
+
+// Star selects a column for each column of the FROM item named Source, or of
+// every FROM item when Source is empty. The columns of a subselect are its
+// headers, those of a set-returning function with several columns are its
+// columns, and those of other items are the keys of every row, in order.
+type Star struct {
+	Source string
+
+	// Offset is the position of the star, or Source, in the query source
+	Offset int
 }
 
 type PathExpression struct {
-	Fields []string
+	Steps []*PathStep
+}
+
+// PathStepKind is the kind of a step of a path expression.
+type PathStepKind int
+
+const (
+	// PathKey is an object key or array index, ->name or ->-1
+	PathKey PathStepKind = iota
+	// PathComputed is a key or index computed from an expression, ->(expr)
+	PathComputed
+	// PathWildcard is every element of an array or value of an object, ->*
+	PathWildcard
+	// PathRecursive is a value and every value nested within it, ->**
+	PathRecursive
+	// PathSlice is a range of the elements of an array, ->1:3
+	PathSlice
+)
+
+// PathStep is a step of a path expression. Wildcards, recursion and slices
+// apply the rest of the path to each element they select, and the path
+// evaluates to an array of the results.
+type PathStep struct {
+	Kind PathStepKind
+
+	// Key is the key or index of a PathKey
+	Key string
+	// Expr is the expression of a PathComputed
+	Expr Expr
+	// Start and End are the bounds of a PathSlice, which are open when nil
+	Start, End *int
+}
+
+func (path *PathExpression) Walk(v Visitor) Expr {
+	for _, step := range path.Steps {
+		if step.Kind == PathComputed {
+			step.Expr.Walk(v)
+		}
+	}
+	return nil
 }
 
 type FromClause struct {
 	Subselects []*FromSubselect
 	Resources  []*FromResource
+	Laterals   []*FromLateral
+
+	// Aliases are the aliases of every item, in the order they're listed
+	Aliases []string
 }
 
 type FromResource struct {
@@ -38,6 +151,9 @@ type FromResource struct {
 	Kind    string
 
 	Namespace string
+	// NamespaceParam is set, instead of Namespace, for NAMESPACE $1 and
+	// NAMESPACE :name
+	NamespaceParam *Parameter
 }
 
 type FromSubselect struct {
@@ -45,6 +161,15 @@ type FromSubselect struct {
 	Select *SelectStatement
 }
 
+// FromLateral is a FROM item evaluated for each row of the items before it,
+// which it can reference: either a set-returning function call, or a LATERAL
+// subselect.
+type FromLateral struct {
+	Alias  string
+	Call   *SetReturningCall
+	Select *SelectStatement
+}
+
 type WhereClause struct {
 	Condition Expr
 }
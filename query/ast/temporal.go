@@ -0,0 +1,297 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saracen/kubeql/query/lexer"
+)
+
+// Timestamps are represented as time.Time, in UTC. Kubernetes timestamps are
+// RFC3339 strings, so strings are parsed as timestamps wherever they're
+// combined with a timestamp or interval.
+
+// Duration is the value of an interval. It is rendered in the same
+// human-readable form as kubectl's AGE column.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	if d < 0 {
+		return "-" + humanDuration(time.Duration(-d))
+	}
+	return humanDuration(time.Duration(d))
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// humanDuration approximates a duration to at most two units, with less
+// precision the longer the duration is.
+func humanDuration(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	minutes := int64(d / time.Minute)
+	hours := int64(d / time.Hour)
+	days := hours / 24
+	years := days / 365
+
+	switch {
+	case seconds < 120:
+		return fmt.Sprintf("%ds", seconds)
+	case minutes < 10:
+		if s := seconds % 60; s != 0 {
+			return fmt.Sprintf("%dm%ds", minutes, s)
+		}
+		return fmt.Sprintf("%dm", minutes)
+	case minutes < 180:
+		return fmt.Sprintf("%dm", minutes)
+	case hours < 8:
+		if m := minutes % 60; m != 0 {
+			return fmt.Sprintf("%dh%dm", hours, m)
+		}
+		return fmt.Sprintf("%dh", hours)
+	case hours < 48:
+		return fmt.Sprintf("%dh", hours)
+	case days < 8:
+		if h := hours % 24; h != 0 {
+			return fmt.Sprintf("%dd%dh", days, h)
+		}
+		return fmt.Sprintf("%dd", days)
+	case years < 2:
+		return fmt.Sprintf("%dd", days)
+	case years < 8:
+		if d := days % 365; d != 0 {
+			return fmt.Sprintf("%dy%dd", years, d)
+		}
+		return fmt.Sprintf("%dy", years)
+	}
+	return fmt.Sprintf("%dy", years)
+}
+
+var intervalUnits = map[string]time.Duration{
+	"ms": time.Millisecond, "millisecond": time.Millisecond, "milliseconds": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+var intervalComponent = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*([a-zA-Z]+)`)
+
+// ParseDuration parses an interval such as "7d", "1h30m" or "2 weeks".
+// Months and years are not supported, as their length varies.
+func ParseDuration(text string) (Duration, error) {
+	s := strings.TrimSpace(text)
+
+	sign := Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+
+	if s == "" {
+		return 0, fmt.Errorf("invalid interval %q", text)
+	}
+
+	var total float64
+	for s != "" {
+		match := intervalComponent.FindStringSubmatch(s)
+		if match == nil {
+			return 0, fmt.Errorf("invalid interval %q", text)
+		}
+
+		unit, ok := intervalUnits[strings.ToLower(match[2])]
+		if !ok {
+			return 0, fmt.Errorf("invalid interval %q, unknown unit %q", text, match[2])
+		}
+
+		num, _ := strconv.ParseFloat(match[1], 64)
+		total += num * float64(unit)
+
+		s = strings.TrimSpace(s[len(match[0]):])
+	}
+
+	if total > math.MaxInt64 {
+		return 0, fmt.Errorf("interval %q out of range", text)
+	}
+
+	return sign * Duration(total), nil
+}
+
+// toTimestamp converts a timestamp, or an RFC3339 string, to a timestamp.
+func toTimestamp(val interface{}) (time.Time, error) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", v)
+		}
+		return t.UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("type mismatch: expected timestamp, got %v", TypeOf(val))
+}
+
+func isTemporal(t Type) bool {
+	return t == TimestampType || t == IntervalType
+}
+
+// temporalOperatorType returns the result type of an operator applied to a
+// timestamp or interval, and whether the operand types are compatible.
+func temporalOperatorType(op Operator, lhs, rhs Type) (Type, bool) {
+	// strings are parsed as timestamps
+	if lhs == StringType {
+		lhs = TimestampType
+	}
+	if rhs == StringType {
+		rhs = TimestampType
+	}
+
+	switch lexer.TokenType(op) {
+	case lexer.Equal, lexer.NotEqual, lexer.LessThan, lexer.LessThanEqual,
+		lexer.GreaterThan, lexer.GreaterThanEqual:
+		return BooleanType, compatible(lhs, rhs)
+
+	case lexer.Add:
+		switch {
+		case lhs == IntervalType && rhs == IntervalType:
+			return IntervalType, true
+		case lhs == TimestampType && isType(rhs, IntervalType), isType(lhs, IntervalType) && rhs == TimestampType:
+			return TimestampType, true
+		case lhs == IntervalType && rhs == AnyType, lhs == AnyType && rhs == IntervalType:
+			return AnyType, true
+		}
+
+	case lexer.Subtract:
+		switch {
+		case lhs == TimestampType && rhs == IntervalType:
+			return TimestampType, true
+		case isType(lhs, TimestampType) && rhs == TimestampType:
+			return IntervalType, true
+		case lhs == IntervalType && isType(rhs, IntervalType):
+			return IntervalType, true
+		case lhs == TimestampType && rhs == AnyType, lhs == AnyType && rhs == IntervalType:
+			return AnyType, true
+		}
+
+	case lexer.Multiply:
+		if (lhs == IntervalType && isType(rhs, IntegerType, FloatType)) ||
+			(isType(lhs, IntegerType, FloatType) && rhs == IntervalType) {
+			return IntervalType, true
+		}
+
+	case lexer.Divide:
+		if lhs == IntervalType && isType(rhs, IntegerType, FloatType) {
+			return IntervalType, true
+		}
+	}
+
+	return AnyType, false
+}
+
+// temporalOp applies an operator to a timestamp or interval.
+func temporalOp(op Operator, lhs, rhs interface{}) (interface{}, error) {
+	lt, rt := TypeOf(lhs), TypeOf(rhs)
+
+	var err error
+	if lt == StringType {
+		if lhs, err = toTimestamp(lhs); err != nil {
+			return nil, err
+		}
+	}
+	if rt == StringType {
+		if rhs, err = toTimestamp(rhs); err != nil {
+			return nil, err
+		}
+	}
+
+	switch l := lhs.(type) {
+	case time.Time:
+		switch r := rhs.(type) {
+		case time.Time:
+			if lexer.TokenType(op) == lexer.Subtract {
+				return Duration(l.Sub(r)), nil
+			}
+
+			cmp := int64(0)
+			switch {
+			case l.Before(r):
+				cmp = -1
+			case l.After(r):
+				cmp = 1
+			}
+			if val, ok := compareOp(op, cmp); ok {
+				return val, nil
+			}
+
+		case Duration:
+			switch lexer.TokenType(op) {
+			case lexer.Add:
+				return l.Add(time.Duration(r)), nil
+			case lexer.Subtract:
+				return l.Add(-time.Duration(r)), nil
+			}
+		}
+
+	case Duration:
+		switch r := rhs.(type) {
+		case Duration:
+			switch lexer.TokenType(op) {
+			case lexer.Add:
+				return l + r, nil
+			case lexer.Subtract:
+				return l - r, nil
+			}
+
+			if val, ok := compareOp(op, int64(l-r)); ok {
+				return val, nil
+			}
+
+		case time.Time:
+			if lexer.TokenType(op) == lexer.Add {
+				return r.Add(time.Duration(l)), nil
+			}
+
+		default:
+			if num, ok := toNumber(r); ok {
+				switch lexer.TokenType(op) {
+				case lexer.Multiply:
+					return Duration(float64(l) * toFloat(num)), nil
+				case lexer.Divide:
+					if toFloat(num) == 0 {
+						return nil, errDivisionByZero
+					}
+					return Duration(float64(l) / toFloat(num)), nil
+				}
+			}
+		}
+
+	default:
+		if r, ok := rhs.(Duration); ok && lexer.TokenType(op) == lexer.Multiply {
+			if num, ok := toNumber(l); ok {
+				return Duration(toFloat(num) * float64(r)), nil
+			}
+		}
+	}
+
+	return nil, typeMismatch(op, lt, rt)
+}
+
+// compareOp applies a comparison operator to the sign of a difference, and
+// reports whether the operator is a comparison.
+func compareOp(op Operator, diff int64) (interface{}, bool) {
+	switch lexer.TokenType(op) {
+	case lexer.Equal, lexer.NotEqual, lexer.LessThan, lexer.LessThanEqual,
+		lexer.GreaterThan, lexer.GreaterThanEqual:
+		val, _ := integerOp(op, diff, 0)
+		return val, true
+	}
+
+	return nil, false
+}
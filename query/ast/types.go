@@ -0,0 +1,286 @@
+package ast
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/saracen/kubeql/query/joiner"
+	"github.com/saracen/kubeql/query/lexer"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Type is the type of a value, either inferred from an expression during
+// semantic analysis or discovered from a value at runtime. AnyType is used
+// when the type cannot be known until data is evaluated.
+type Type int
+
+const (
+	AnyType Type = iota
+	NullType
+	BooleanType
+	IntegerType
+	FloatType
+	StringType
+	ArrayType
+	ObjectType
+	TimestampType
+	IntervalType
+	QuantityType
+)
+
+func (t Type) String() string {
+	switch t {
+	case NullType:
+		return "null"
+	case BooleanType:
+		return "boolean"
+	case IntegerType:
+		return "integer"
+	case FloatType:
+		return "float"
+	case StringType:
+		return "string"
+	case ArrayType:
+		return "array"
+	case ObjectType:
+		return "object"
+	case TimestampType:
+		return "timestamp"
+	case IntervalType:
+		return "interval"
+	case QuantityType:
+		return "quantity"
+	}
+	return "any"
+}
+
+func (t Type) IsNumeric() bool {
+	return t == IntegerType || t == FloatType
+}
+
+// TypeOf returns the type of a runtime value.
+func TypeOf(val interface{}) Type {
+	switch v := val.(type) {
+	case nil:
+		return NullType
+	case bool:
+		return BooleanType
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		return IntegerType
+	case float32, float64:
+		return FloatType
+	case string:
+		return StringType
+	case []interface{}:
+		return ArrayType
+	case map[string]interface{}, joiner.Tuple:
+		return ObjectType
+	case time.Time:
+		return TimestampType
+	case Duration:
+		return IntervalType
+	case resource.Quantity:
+		return QuantityType
+	case Secret:
+		return TypeOf(v.Val)
+	}
+	return AnyType
+}
+
+// StaticType infers the type of an expression without evaluating it.
+func StaticType(expr Expr) Type {
+	switch expr := expr.(type) {
+	case *String:
+		return StringType
+	case *Integer:
+		return IntegerType
+	case *Float:
+		return FloatType
+	case *Boolean:
+		return BooleanType
+	case *Timestamp:
+		return TimestampType
+	case *ObjectLiteral:
+		return ObjectType
+	case *ArrayLiteral:
+		return ArrayType
+	case *Interval:
+		return IntervalType
+	case *ParenExpr:
+		if expr.PathExpr == nil {
+			return StaticType(expr.Expr)
+		}
+	case *BinaryExpr:
+		if t, ok := operatorType(expr.Op, StaticType(expr.LHS), StaticType(expr.RHS)); ok {
+			return t
+		}
+	case *UnaryExpr:
+		if t, ok := unaryOperatorType(expr.Op, StaticType(expr.Expr)); ok {
+			return t
+		}
+	case *InExpr, *BetweenExpr, *MatchExpr:
+		return BooleanType
+	case *CaseExpr:
+		// the result type is only known if every branch agrees
+		t := NullType
+		if expr.Else != nil {
+			t = StaticType(expr.Else)
+		}
+		for _, when := range expr.Whens {
+			switch result := StaticType(when.Result); {
+			case t == NullType:
+				t = result
+			case result != t && result != NullType:
+				return AnyType
+			}
+		}
+		return t
+	case *Call:
+		if expr.PathExpr == nil && expr.Func != nil {
+			return expr.Func.Returns
+		}
+	case *AggregateCall:
+		if expr.PathExpr == nil && expr.Func != nil {
+			return expr.Func.Returns
+		}
+	case *WindowCall:
+		switch {
+		case expr.PathExpr != nil:
+		case expr.Func != nil:
+			return expr.Func.Returns
+		case expr.Aggregate != nil:
+			return expr.Aggregate.Returns
+		}
+	}
+	return AnyType
+}
+
+// operatorType returns the result type of applying an operator to operands of
+// the given types, and whether the operand types are compatible. Operands of
+// AnyType are assumed compatible until their runtime type is known.
+func operatorType(op Operator, lhs, rhs Type) (Type, bool) {
+	switch lexer.TokenType(op) {
+	case lexer.And, lexer.Or:
+		if !isType(lhs, NullType, BooleanType) || !isType(rhs, NullType, BooleanType) {
+			return AnyType, false
+		}
+		return BooleanType, true
+	}
+
+	if lhs == NullType || rhs == NullType {
+		return NullType, true
+	}
+
+	if lexer.TokenType(op) != lexer.Concat && (isTemporal(lhs) || isTemporal(rhs)) {
+		return temporalOperatorType(op, lhs, rhs)
+	}
+
+	if lexer.TokenType(op) != lexer.Concat && (lhs == QuantityType || rhs == QuantityType) {
+		return quantityOperatorType(op, lhs, rhs)
+	}
+
+	switch lexer.TokenType(op) {
+	case lexer.Equal, lexer.NotEqual:
+		// objects and arrays are compared by value
+		if !isType(lhs, BooleanType, IntegerType, FloatType, StringType, ObjectType, ArrayType) ||
+			!isType(rhs, BooleanType, IntegerType, FloatType, StringType, ObjectType, ArrayType) {
+			return AnyType, false
+		}
+		return BooleanType, compatible(lhs, rhs)
+
+	case lexer.Contains, lexer.ContainedBy:
+		return BooleanType, isType(lhs, ObjectType, ArrayType) && isType(rhs, ObjectType, ArrayType)
+
+	case lexer.HasKey:
+		return BooleanType, isType(lhs, ObjectType, ArrayType) && isType(rhs, StringType)
+
+	case lexer.HasAnyKey, lexer.HasAllKeys:
+		return BooleanType, isType(lhs, ObjectType, ArrayType) && isType(rhs, ArrayType)
+
+	case lexer.LessThan, lexer.LessThanEqual, lexer.GreaterThan, lexer.GreaterThanEqual:
+		if !isType(lhs, IntegerType, FloatType, StringType) ||
+			!isType(rhs, IntegerType, FloatType, StringType) {
+			return AnyType, false
+		}
+		return BooleanType, compatible(lhs, rhs)
+
+	case lexer.Add, lexer.Subtract, lexer.Multiply, lexer.Divide, lexer.Modulo:
+		if !isType(lhs, IntegerType, FloatType) || !isType(rhs, IntegerType, FloatType) {
+			return AnyType, false
+		}
+		switch {
+		case lhs == FloatType || rhs == FloatType:
+			return FloatType, true
+		case lhs == IntegerType && rhs == IntegerType:
+			return IntegerType, true
+		}
+		return AnyType, true
+
+	case lexer.Concat:
+		if !isType(lhs, BooleanType, IntegerType, FloatType, StringType, TimestampType, IntervalType, QuantityType) ||
+			!isType(rhs, BooleanType, IntegerType, FloatType, StringType, TimestampType, IntervalType, QuantityType) {
+			return AnyType, false
+		}
+		// at least one side must be a string, the other is converted
+		return StringType, isType(lhs, StringType) || isType(rhs, StringType)
+	}
+
+	return AnyType, false
+}
+
+// unaryOperatorType returns the result type of applying a prefix operator to
+// an operand of the given type, and whether the operand type is compatible.
+func unaryOperatorType(op Operator, t Type) (Type, bool) {
+	switch lexer.TokenType(op) {
+	case lexer.Not:
+		return BooleanType, isType(t, NullType, BooleanType)
+
+	case lexer.Subtract:
+		if t == NullType {
+			return NullType, true
+		}
+		return t, isType(t, IntegerType, FloatType, IntervalType, QuantityType)
+	}
+
+	return AnyType, false
+}
+
+// isType reports whether t is one of types, treating AnyType as matching
+// everything.
+func isType(t Type, types ...Type) bool {
+	if t == AnyType {
+		return true
+	}
+	for _, typ := range types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func compatible(lhs, rhs Type) bool {
+	if lhs == AnyType || rhs == AnyType || lhs == rhs {
+		return true
+	}
+	return lhs.IsNumeric() && rhs.IsNumeric()
+}
+
+// EvalError is an error produced while analysing or evaluating an expression,
+// located at an offset in the query source.
+type EvalError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("%v (offset: %v)", e.Msg, e.Offset)
+}
+
+func typeMismatch(op Operator, lhs, rhs Type) error {
+	return fmt.Errorf("type mismatch: %v %v %v", lhs, op, rhs)
+}
+
+func unaryTypeMismatch(op Operator, t Type) error {
+	return fmt.Errorf("type mismatch: %v %v", op, t)
+}
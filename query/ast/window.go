@@ -0,0 +1,341 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/saracen/kubeql/query/lexer"
+)
+
+// Window is the OVER clause of a window function call. Rows are divided into
+// partitions of equal PartitionBy values, and ordered within each partition by
+// OrderBy.
+type Window struct {
+	PartitionBy []Expr
+	OrderBy     []*OrderTerm
+
+	// Frame is the rows of a partition that an aggregate is computed over for
+	// each row. Without a frame, it's the rows up to the row and the rows
+	// with equal OrderBy values, or every row if there's no OrderBy.
+	Frame *WindowFrame
+}
+
+func (w *Window) Walk(v Visitor) Expr {
+	for _, expr := range w.PartitionBy {
+		expr.Walk(v)
+	}
+	for _, term := range w.OrderBy {
+		term.Expr.Walk(v)
+	}
+	return nil
+}
+
+// OrderTerm is an expression rows are ordered by. Nulls are ordered after
+// every other value, or before them when Desc is set.
+type OrderTerm struct {
+	Expr Expr
+	Desc bool
+}
+
+// WindowFrame is a ROWS frame: the rows between two bounds relative to a row.
+type WindowFrame struct {
+	Start, End FrameBound
+}
+
+// FrameBoundKind is the kind of a window frame bound.
+type FrameBoundKind int
+
+const (
+	UnboundedPreceding FrameBoundKind = iota
+	Preceding
+	CurrentRow
+	Following
+	UnboundedFollowing
+)
+
+// FrameBound is a bound of a window frame.
+type FrameBound struct {
+	Kind FrameBoundKind
+	// Offset is the number of rows of a Preceding or Following bound
+	Offset int
+}
+
+// WindowPartition is a partition of the rows of a window, in the window's
+// order.
+type WindowPartition struct {
+	// Args are the arguments of each row
+	Args [][]interface{}
+	// Peers numbers each row's group of rows with equal ORDER BY values,
+	// from 0
+	Peers []int
+	// Frames are the start and end, exclusive, of each row's window frame
+	Frames [][2]int
+}
+
+// Evaluate computes the call's result for each of rows, which are then
+// evaluated in turn by setting Row.
+func (expr *WindowCall) Evaluate(rows []map[string]interface{}) error {
+	partitions, err := expr.Window.partition(rows)
+	if err != nil {
+		return err
+	}
+
+	expr.Results = make([]interface{}, len(rows))
+	for _, partition := range partitions {
+		if err := expr.Window.sort(partition); err != nil {
+			return &EvalError{Offset: expr.Offset, Msg: err.Error()}
+		}
+		p := expr.Window.newPartition(partition.rows, partition.keys)
+
+		for i, row := range partition.rows {
+			args, err := evalArgs(expr.Args, rows[row])
+			if err != nil {
+				return err
+			}
+			if expr.Func != nil {
+				if err := expr.checkArgs(args); err != nil {
+					return err
+				}
+			}
+			p.Args[i] = args
+		}
+
+		for i, row := range partition.rows {
+			val, err := expr.evalWindow(p, i)
+			if err != nil {
+				return &EvalError{Offset: expr.Offset, Msg: err.Error()}
+			}
+			expr.Results[row] = val
+		}
+	}
+
+	return nil
+}
+
+// checkArgs checks and converts the arguments of a window function for a row.
+// Arguments of the wrong type are null when permissive.
+func (expr *WindowCall) checkArgs(args []interface{}) error {
+	sig := &expr.Func.Signature
+	for i, arg := range args {
+		declared, actual := sig.argType(i), TypeOf(arg)
+		if argTypeMatches(declared, actual) {
+			continue
+		}
+		if !expr.Permissive {
+			return &EvalError{Offset: expr.Offset, Msg: argTypeMismatch(expr.Name, i, declared, actual).Error()}
+		}
+		args[i] = nil
+	}
+
+	if err := sig.convertArgs(args); err != nil {
+		return &EvalError{Offset: expr.Offset, Msg: err.Error()}
+	}
+
+	return nil
+}
+
+// evalWindow returns the result for a row of a partition. Aggregates are
+// stepped through the rows of the row's frame.
+func (expr *WindowCall) evalWindow(p *WindowPartition, row int) (interface{}, error) {
+	if expr.Func != nil {
+		return expr.Func.Eval(p, row)
+	}
+
+	aggregator, secret := expr.Aggregate.New(), false
+	for i := p.Frames[row][0]; i < p.Frames[row][1]; i++ {
+		args := append([]interface{}(nil), p.Args[i]...)
+
+		stepped, err := stepAggregate(expr.Aggregate, aggregator, args, expr.Permissive)
+		if err != nil {
+			return nil, err
+		}
+		secret = secret || stepped
+	}
+
+	val, err := aggregator.Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return concealSecret(val, secret), nil
+}
+
+type windowPartition struct {
+	rows []int
+	keys [][]interface{}
+}
+
+// partition divides rows into partitions, in the order of their first rows,
+// returning the indexes and ORDER BY values of their rows.
+func (w *Window) partition(rows []map[string]interface{}) ([]*windowPartition, error) {
+	var partitions []*windowPartition
+	var values [][]interface{}
+
+	for i, row := range rows {
+		value, err := evalArgs(w.PartitionBy, row)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := evalArgs(orderExprs(w.OrderBy), row)
+		if err != nil {
+			return nil, err
+		}
+
+		var partition *windowPartition
+		for j := range values {
+			if DeepEqual(values[j], value) {
+				partition = partitions[j]
+				break
+			}
+		}
+		if partition == nil {
+			partition = &windowPartition{}
+			partitions = append(partitions, partition)
+			values = append(values, value)
+		}
+
+		partition.rows = append(partition.rows, i)
+		partition.keys = append(partition.keys, keys)
+	}
+
+	return partitions, nil
+}
+
+func orderExprs(terms []*OrderTerm) []Expr {
+	exprs := make([]Expr, len(terms))
+	for i, term := range terms {
+		exprs[i] = term.Expr
+	}
+	return exprs
+}
+
+// sort orders the rows of a partition by their ORDER BY values, keeping rows
+// with equal values in the order they were seen.
+func (w *Window) sort(partition *windowPartition) error {
+	var err error
+	sort.Stable(partitionSorter{partition, func(lhs, rhs []interface{}) bool {
+		for i, term := range w.OrderBy {
+			cmp, cmpErr := compareValues(lhs[i], rhs[i])
+			if cmpErr != nil {
+				if err == nil {
+					err = cmpErr
+				}
+				return false
+			}
+			if term.Desc {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	}})
+
+	return err
+}
+
+type partitionSorter struct {
+	*windowPartition
+	less func(lhs, rhs []interface{}) bool
+}
+
+func (s partitionSorter) Len() int { return len(s.rows) }
+
+func (s partitionSorter) Less(i, j int) bool { return s.less(s.keys[i], s.keys[j]) }
+
+func (s partitionSorter) Swap(i, j int) {
+	s.rows[i], s.rows[j] = s.rows[j], s.rows[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// compareValues orders two values, with nulls after every other value.
+func compareValues(lhs, rhs interface{}) (int, error) {
+	switch {
+	case DeepEqual(lhs, rhs):
+		return 0, nil
+	case lhs == nil:
+		return 1, nil
+	case rhs == nil:
+		return -1, nil
+	}
+
+	lhs, _ = revealSecret(lhs)
+	rhs, _ = revealSecret(rhs)
+
+	less, err := op(lhs, Operator(lexer.LessThan), rhs)
+	if err != nil {
+		return 0, fmt.Errorf("cannot order by %v and %v", TypeOf(lhs), TypeOf(rhs))
+	}
+	if less == true {
+		return -1, nil
+	}
+	return 1, nil
+}
+
+// newPartition numbers the peer groups of the ordered rows of a partition, and
+// finds the frame of each row.
+func (w *Window) newPartition(rows []int, keys [][]interface{}) *WindowPartition {
+	n := len(rows)
+	p := &WindowPartition{
+		Args:   make([][]interface{}, n),
+		Peers:  make([]int, n),
+		Frames: make([][2]int, n),
+	}
+
+	for i := 1; i < n; i++ {
+		p.Peers[i] = p.Peers[i-1]
+		if !DeepEqual(keys[i], keys[i-1]) {
+			p.Peers[i]++
+		}
+	}
+
+	for i := range rows {
+		switch {
+		case w.Frame != nil:
+			start, end := w.Frame.Start.index(i, n), w.Frame.End.index(i, n)+1
+			start, end = clamp(start, 0, n), clamp(end, 0, n)
+			if end < start {
+				end = start
+			}
+			p.Frames[i] = [2]int{start, end}
+
+		case len(w.OrderBy) > 0:
+			end := i + 1
+			for end < n && p.Peers[end] == p.Peers[i] {
+				end++
+			}
+			p.Frames[i] = [2]int{0, end}
+
+		default:
+			p.Frames[i] = [2]int{0, n}
+		}
+	}
+
+	return p
+}
+
+// index returns the index of the row a bound refers to, for row i of n rows.
+func (b FrameBound) index(i, n int) int {
+	switch b.Kind {
+	case UnboundedPreceding:
+		return -1
+	case Preceding:
+		return i - b.Offset
+	case Following:
+		return i + b.Offset
+	case UnboundedFollowing:
+		return n
+	}
+	return i
+}
+
+func clamp(i, min, max int) int {
+	switch {
+	case i < min:
+		return min
+	case i > max:
+		return max
+	}
+	return i
+}
@@ -2,9 +2,11 @@ package query
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/saracen/kubeql/query/ast"
 	"github.com/saracen/kubeql/query/joiner"
+	"github.com/saracen/kubeql/query/lexer"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -24,23 +26,136 @@ type Row struct {
 
 type Session struct {
 	pool      dynamic.ClientPool
-	resources map[schema.GroupVersionKind]*unstructured.UnstructuredList
+	resources map[resourceKey]*unstructured.UnstructuredList
+	options   Options
+
+	// tables are the results of the common table expressions in scope
+	tables map[string]*Results
+
+	// folded are the calls folded by the execution, which are unfolded once
+	// it's finished
+	folded []*ast.Call
+}
+
+// resourceKey identifies the resources listed from a namespace, or from every
+// namespace when namespace is empty.
+type resourceKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+}
+
+// fold folds the calls of an expression, keeping them to be unfolded.
+func (session *Session) fold(walker ast.ExprWalker, data map[string]interface{}) {
+	session.folded = append(session.folded, ast.Fold(walker, data)...)
+}
+
+// Options control how a query is executed.
+type Options struct {
+	// Permissive evaluates operators applied to mismatched types as null
+	// rather than failing the query.
+	Permissive bool
+
+	// ShowSecrets outputs the data of secrets, rather than redacting it.
+	ShowSecrets bool
 }
 
 func ExecuteQuery(c *rest.Config, query string) (*Results, error) {
-	parser := NewStringParser(query)
+	return ExecuteQueryWithOptions(c, query, Options{})
+}
 
-	s, err := parser.Parse()
+func ExecuteQueryWithOptions(c *rest.Config, query string, options Options) (*Results, error) {
+	prepared, err := Prepare(c, query, options)
 	if err != nil {
 		return nil, err
 	}
 
-	session := &Session{
-		dynamic.NewDynamicClientPool(c),
-		make(map[schema.GroupVersionKind]*unstructured.UnstructuredList),
+	return prepared.Execute()
+}
+
+// locateError adds the query source leading up to an expression error.
+func locateError(query string, err error) error {
+	if err, ok := err.(*ast.EvalError); ok {
+		return fmt.Errorf("%v", sourceError(query, err.Msg, err.Offset))
+	}
+
+	return err
+}
+
+// checkSelectStatement performs semantic analysis of a statement and any
+// statements nested within it.
+func checkSelectStatement(s *ast.SelectStatement) error {
+	if s.With != nil {
+		for _, cte := range s.With.Tables {
+			if err := checkSelectStatement(cte.Select); err != nil {
+				return err
+			}
+			if cte.Recursive != nil {
+				if err := checkSelectStatement(cte.Recursive); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if s.Compound != nil {
+		if err := checkSelectStatement(s.Compound.Left); err != nil {
+			return err
+		}
+		return checkSelectStatement(s.Compound.Right)
+	}
+
+	for _, expr := range s.SelectClause.Expressions {
+		if expr.Star != nil && expr.Star.Source != "" && !hasAlias(s.FromClause, expr.Star.Source) {
+			return &ast.EvalError{Offset: expr.Star.Offset, Msg: fmt.Sprintf("missing FROM item %v", expr.Star.Source)}
+		}
+	}
+
+	walkers := []ast.ExprWalker{s.SelectClause}
+	if s.WhereClause != nil {
+		walkers = append(walkers, s.WhereClause)
+	}
+
+	for _, walker := range walkers {
+		if err := ast.Check(walker); err != nil {
+			return err
+		}
+
+		var subselects []*ast.Subselect
+		ast.Inspect(walker, func(expr ast.Expr) bool {
+			if subselect, ok := expr.(*ast.Subselect); ok {
+				subselects = append(subselects, subselect)
+				return false
+			}
+			return true
+		})
+
+		for _, subselect := range subselects {
+			if err := checkSelectStatement(subselect.Select); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, subselect := range s.FromClause.Subselects {
+		if err := checkSelectStatement(subselect.Select); err != nil {
+			return err
+		}
+	}
+
+	for _, lateral := range s.FromClause.Laterals {
+		if lateral.Call != nil {
+			if err := ast.Check(lateral.Call); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := checkSelectStatement(lateral.Select); err != nil {
+			return err
+		}
 	}
 
-	return executeSelectStatement(session, s, nil)
+	return nil
 }
 
 type UnstructuredListIterator struct {
@@ -107,16 +222,38 @@ func (i *ResultIterator) Next() joiner.Tuple {
 	return result
 }
 
-func getResourceIterators(session *Session, resources []*ast.FromResource) ([]joiner.Iterator, error) {
+// getResourceIterators returns iterators of the resources of a FROM clause.
+// outer is the data of the enclosing statement, which holds any parameters.
+func getResourceIterators(session *Session, resources []*ast.FromResource, outer map[string]interface{}) ([]joiner.Iterator, error) {
 	var iterators []joiner.Iterator
 	for _, resource := range resources {
+		namespace := resource.Namespace
+		if resource.NamespaceParam != nil {
+			val, err := resource.NamespaceParam.Eval(outer)
+			if err != nil {
+				return nil, err
+			}
+
+			str, ok := val.(string)
+			if !ok {
+				return nil, &ast.EvalError{Offset: resource.NamespaceParam.Offset, Msg: fmt.Sprintf("NAMESPACE expects a string, got %v", ast.TypeOf(val))}
+			}
+			namespace = str
+		}
+
 		gvk := schema.GroupVersionKind{
 			Group:   resource.Group,
 			Version: resource.Version,
 			Kind:    resource.Kind,
 		}
 
-		data, ok := session.resources[gvk]
+		if results, ok := session.tables[resource.Kind]; ok && resource.Group == "" {
+			iterators = append(iterators, &ResultIterator{name: resource.Alias, data: results})
+			continue
+		}
+
+		key := resourceKey{gvk: gvk, namespace: namespace}
+		data, ok := session.resources[key]
 		if !ok {
 			client, err := session.pool.ClientForGroupVersionKind(gvk)
 			if err != nil {
@@ -124,7 +261,7 @@ func getResourceIterators(session *Session, resources []*ast.FromResource) ([]jo
 			}
 
 			options := metav1.ListOptions{}
-			list, err := client.Resource(&metav1.APIResource{Name: gvk.Kind, Group: gvk.Group, Version: gvk.Version, Namespaced: true}, resource.Namespace).List(options)
+			list, err := client.Resource(&metav1.APIResource{Name: gvk.Kind, Group: gvk.Group, Version: gvk.Version, Namespaced: true}, namespace).List(options)
 			if err != nil {
 				return nil, err
 			}
@@ -134,7 +271,11 @@ func getResourceIterators(session *Session, resources []*ast.FromResource) ([]jo
 				return nil, fmt.Errorf("Invalid kubernetes resource")
 			}
 
-			session.resources[gvk] = data
+			if !session.options.ShowSecrets {
+				concealSecrets(gvk, data)
+			}
+
+			session.resources[key] = data
 		}
 
 		iterators = append(iterators, &UnstructuredListIterator{name: resource.Alias, data: data})
@@ -143,6 +284,216 @@ func getResourceIterators(session *Session, resources []*ast.FromResource) ([]jo
 	return iterators, nil
 }
 
+// concealSecrets wraps the data of secrets, so that it, and anything derived
+// from it, is redacted in the results.
+func concealSecrets(gvk schema.GroupVersionKind, list *unstructured.UnstructuredList) {
+	for _, item := range list.Items {
+		if item.GetKind() != "Secret" && !(gvk.Group == "" && gvk.Kind == "secrets") {
+			continue
+		}
+
+		for _, field := range []string{"data", "stringData"} {
+			data, ok := item.Object[field].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key, val := range data {
+				if val != nil {
+					data[key] = ast.Secret{Val: val}
+				}
+			}
+		}
+	}
+}
+
+// maxRecursion limits the number of times a recursive common table expression
+// is evaluated, so that a cycle with UNION ALL fails rather than never ending.
+const maxRecursion = 1000
+
+// prepareWith evaluates the common table expressions of a WITH clause, each of
+// which can reference those before it, and returns a function restoring the
+// tables that were in scope before.
+func prepareWith(session *Session, with *ast.WithClause, data map[string]interface{}) (func(), error) {
+	previous := make(map[string]*Results)
+	restore := func() {
+		for name, results := range previous {
+			if results == nil {
+				delete(session.tables, name)
+			} else {
+				session.tables[name] = results
+			}
+		}
+	}
+
+	for _, cte := range with.Tables {
+		if _, ok := previous[cte.Name]; !ok {
+			previous[cte.Name] = session.tables[cte.Name]
+		}
+
+		results, err := evalCommonTableExpr(session, cte, data)
+		if err != nil {
+			return restore, err
+		}
+		session.tables[cte.Name] = results
+	}
+
+	return restore, nil
+}
+
+// evalCommonTableExpr evaluates a common table expression. The recursive
+// statement of a recursive expression is evaluated against the rows it
+// last returned, starting with those of the initial statement, until it
+// returns no new rows.
+func evalCommonTableExpr(session *Session, cte *ast.CommonTableExpr, data map[string]interface{}) (*Results, error) {
+	results, err := executeSelectStatement(session, cte.Select, data)
+	if err != nil || cte.Recursive == nil {
+		return results, err
+	}
+
+	if !cte.UnionAll {
+		results.Rows = distinctRows(results.Rows, nil)
+	}
+
+	working := &Results{Headers: results.Headers, Rows: results.Rows}
+	for i := 0; len(working.Rows) > 0; i++ {
+		if i == maxRecursion {
+			return nil, fmt.Errorf("recursive query %v exceeded %v iterations", cte.Name, maxRecursion)
+		}
+
+		session.tables[cte.Name] = working
+		next, err := executeSelectStatement(session, cte.Recursive, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(next.Headers) != len(results.Headers) {
+			return nil, fmt.Errorf("each UNION query must have the same number of columns")
+		}
+
+		if !cte.UnionAll {
+			next.Rows = distinctRows(next.Rows, results.Rows)
+		}
+		results.Rows = append(results.Rows, next.Rows...)
+		working = &Results{Headers: results.Headers, Rows: next.Rows}
+	}
+
+	return results, nil
+}
+
+// executeCompoundStatement combines the rows of two statements, taking the
+// headers of the first.
+func executeCompoundStatement(session *Session, c *ast.CompoundStatement, data map[string]interface{}) (*Results, error) {
+	lhs, err := executeSelectStatement(session, c.Left, data)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := executeSelectStatement(session, c.Right, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lhs.Headers) != len(rhs.Headers) {
+		return nil, fmt.Errorf("each %v query must have the same number of columns", c.Op)
+	}
+
+	results := &Results{Headers: lhs.Headers}
+	switch lexer.TokenType(c.Op) {
+	case lexer.Union:
+		results.Rows = append(lhs.Rows, rhs.Rows...)
+		if !c.All {
+			results.Rows = distinctRows(results.Rows, nil)
+		}
+
+	case lexer.Intersect, lexer.Except:
+		rows := lhs.Rows
+		if !c.All {
+			rows = distinctRows(rows, nil)
+		}
+
+		// with ALL, each row of the right statement matches only one row
+		matched := make([]bool, len(rhs.Rows))
+		for _, row := range rows {
+			found := false
+			for i, other := range rhs.Rows {
+				if (!c.All || !matched[i]) && rowsEqual(row, other) {
+					matched[i], found = true, true
+					break
+				}
+			}
+
+			if found == (lexer.TokenType(c.Op) == lexer.Intersect) {
+				results.Rows = append(results.Rows, row)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// distinctRows returns the rows that aren't equal to a row before them or to
+// a row of seen.
+func distinctRows(rows []*Row, seen []*Row) []*Row {
+	var distinct []*Row
+	for _, row := range rows {
+		if !containsRow(seen, row) && !containsRow(distinct, row) {
+			distinct = append(distinct, row)
+		}
+	}
+
+	return distinct
+}
+
+func containsRow(rows []*Row, row *Row) bool {
+	for _, other := range rows {
+		if rowsEqual(row, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// rowsEqual reports whether two rows have deeply equal columns.
+func rowsEqual(lhs, rhs *Row) bool {
+	if len(lhs.Columns) != len(rhs.Columns) {
+		return false
+	}
+	for i := range lhs.Columns {
+		if !ast.DeepEqual(lhs.Columns[i], rhs.Columns[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// lateralIterator returns a function that iterates the rows of a lateral FROM
+// item for a row of the items before it, recording the columns of subselects.
+func lateralIterator(session *Session, lateral *ast.FromLateral, data map[string]interface{}, columns map[string][]string) func(joiner.Tuple) (joiner.Iterator, error) {
+	return func(tuple joiner.Tuple) (joiner.Iterator, error) {
+		item := make(joiner.Tuple).Merge(data, tuple)
+
+		if lateral.Select != nil {
+			results, err := executeSelectStatement(session, lateral.Select, item)
+			if err != nil {
+				return nil, err
+			}
+			columns[lateral.Alias] = results.Headers
+
+			return &ResultIterator{name: lateral.Alias, data: results}, nil
+		}
+
+		rows, err := lateral.Call.Eval(item)
+		if err != nil {
+			return nil, err
+		}
+
+		var tuples []joiner.Tuple
+		for _, row := range rows.([]interface{}) {
+			tuples = append(tuples, joiner.Tuple{lateral.Alias: row})
+		}
+		return joiner.NewTupleIterator(tuples...), nil
+	}
+}
+
 func prepareSubselects(session *Session, walker ast.ExprWalker) {
 	ast.Inspect(walker, func(expr ast.Expr) bool {
 		subselect, ok := expr.(*ast.Subselect)
@@ -175,33 +526,399 @@ func prepareSubselects(session *Session, walker ast.ExprWalker) {
 	})
 }
 
+// prepareAggregates returns the aggregate function calls of a statement, with
+// new aggregators ready to be stepped through each row.
+func prepareAggregates(s *ast.SelectStatement) ([]*ast.AggregateCall, error) {
+	var aggregates []*ast.AggregateCall
+	var err error
+
+	ast.Inspect(s.SelectClause, func(expr ast.Expr) bool {
+		if err != nil {
+			return false
+		}
+
+		switch expr := expr.(type) {
+		case *ast.Subselect:
+			return false
+
+		case *ast.AggregateCall:
+			for _, arg := range expr.Args {
+				ast.Inspect(arg, func(nested ast.Expr) bool {
+					if nested, ok := nested.(*ast.AggregateCall); ok && err == nil {
+						err = &ast.EvalError{Offset: nested.Offset, Msg: "aggregate function calls cannot be nested"}
+					}
+					return err == nil
+				})
+			}
+
+			expr.Reset()
+			aggregates = append(aggregates, expr)
+			return false
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.WhereClause != nil {
+		ast.Inspect(s.WhereClause, func(expr ast.Expr) bool {
+			if expr, ok := expr.(*ast.AggregateCall); ok && err == nil {
+				err = &ast.EvalError{Offset: expr.Offset, Msg: "aggregate functions are not allowed in WHERE"}
+			}
+			_, subselect := expr.(*ast.Subselect)
+			return err == nil && !subselect
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(aggregates) == 0 {
+		return nil, nil
+	}
+
+	// every other reference to a resource must be within an aggregate
+	ast.Inspect(s.SelectClause, func(expr ast.Expr) bool {
+		switch expr := expr.(type) {
+		case *ast.Subselect, *ast.AggregateCall:
+			return false
+
+		case *ast.Reference:
+			if err == nil {
+				err = fmt.Errorf("%v must be used in an aggregate function", expr.Name)
+			}
+		}
+
+		return err == nil
+	})
+
+	for _, expr := range s.SelectClause.Expressions {
+		if expr.Star != nil && err == nil {
+			err = &ast.EvalError{Offset: expr.Star.Offset, Msg: "* cannot be used with aggregate functions"}
+		}
+	}
+
+	return aggregates, err
+}
+
+// prepareWindows returns the window function calls of a statement.
+func prepareWindows(s *ast.SelectStatement) ([]*ast.WindowCall, error) {
+	var windows []*ast.WindowCall
+	var err error
+
+	// nested reports an error for any window function call within walker
+	nested := func(walker ast.ExprWalker, msg string) {
+		ast.Inspect(walker, func(expr ast.Expr) bool {
+			if expr, ok := expr.(*ast.WindowCall); ok && err == nil {
+				err = &ast.EvalError{Offset: expr.Offset, Msg: msg}
+			}
+			_, subselect := expr.(*ast.Subselect)
+			return err == nil && !subselect
+		})
+	}
+
+	ast.Inspect(s.SelectClause, func(expr ast.Expr) bool {
+		if err != nil {
+			return false
+		}
+
+		switch expr := expr.(type) {
+		case *ast.Subselect:
+			return false
+
+		case *ast.AggregateCall:
+			for _, arg := range expr.Args {
+				nested(arg, "aggregate function calls cannot contain window function calls")
+			}
+
+		case *ast.WindowCall:
+			for _, arg := range expr.Args {
+				nested(arg, "window function calls cannot be nested")
+			}
+			nested(expr.Window, "window function calls cannot be nested")
+
+			windows = append(windows, expr)
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.WhereClause != nil {
+		nested(s.WhereClause, "window functions are not allowed in WHERE")
+	}
+
+	return windows, err
+}
+
+// evalRows evaluates window function calls over every row, then evaluates
+// each row with their results.
+func evalRows(clause *ast.SelectClause, windows []*ast.WindowCall, items []map[string]interface{}) ([]*Row, error) {
+	for _, window := range windows {
+		if err := window.Evaluate(items); err != nil {
+			return nil, err
+		}
+	}
+
+	var rows []*Row
+	for i, item := range items {
+		for _, window := range windows {
+			window.Row = i
+		}
+
+		row, err := evalRow(clause, item)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func hasStars(clause *ast.SelectClause) bool {
+	for _, expr := range clause.Expressions {
+		if expr.Star != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAlias(from *ast.FromClause, alias string) bool {
+	for _, name := range from.Aliases {
+		if name == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// expandStars returns a select clause with each star replaced by the columns
+// of the FROM items it selects, in the order the items are listed.
+func expandStars(s *ast.SelectStatement, columns map[string][]string, items []map[string]interface{}) *ast.SelectClause {
+	clause := &ast.SelectClause{}
+	for _, expr := range s.SelectClause.Expressions {
+		if expr.Star == nil {
+			clause.Expressions = append(clause.Expressions, expr)
+			continue
+		}
+
+		sources := s.FromClause.Aliases
+		if expr.Star.Source != "" {
+			sources = []string{expr.Star.Source}
+		}
+		for _, source := range sources {
+			clause.Expressions = append(clause.Expressions, starColumns(source, columns, items)...)
+		}
+	}
+
+	return clause
+}
+
+// starColumns returns the columns of a FROM item: its known columns, or the
+// sorted keys of every row, or if its rows aren't objects, a single column of
+// the item's value.
+func starColumns(source string, columns map[string][]string, items []map[string]interface{}) []*ast.SelectExpression {
+	keys, ok := columns[source]
+	if !ok {
+		seen := make(map[string]bool)
+		for _, item := range items {
+			switch val := item[source].(type) {
+			case nil:
+
+			case map[string]interface{}:
+				for key := range val {
+					seen[key] = true
+				}
+
+			case joiner.Tuple:
+				for key := range val {
+					seen[key] = true
+				}
+
+			default:
+				return []*ast.SelectExpression{{Alias: source, Condition: &ast.Reference{Name: source}}}
+			}
+		}
+
+		for key := range seen {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	exprs := make([]*ast.SelectExpression, len(keys))
+	for i, key := range keys {
+		path := &ast.PathExpression{Steps: []*ast.PathStep{{Kind: ast.PathKey, Key: key}}}
+		exprs[i] = &ast.SelectExpression{Alias: key, Condition: &ast.Reference{Name: source, PathExpr: path}}
+	}
+
+	return exprs
+}
+
+func evalRow(clause *ast.SelectClause, item map[string]interface{}) (*Row, error) {
+	row := &Row{}
+	for _, expr := range clause.Expressions {
+		evaled, err := expr.Condition.Eval(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if subres, ok := evaled.(*Results); ok {
+			if expr.Alias == "" {
+				expr.Alias = subres.Headers[0]
+			}
+			evaled = subres.Rows[0].Columns[0]
+		}
+
+		row.Columns = append(row.Columns, evaled)
+	}
+
+	return row, nil
+}
+
+func preparePermissive(walker ast.ExprWalker) {
+	ast.Inspect(walker, func(expr ast.Expr) bool {
+		switch expr := expr.(type) {
+		case *ast.BinaryExpr:
+			expr.Permissive = true
+		case *ast.UnaryExpr:
+			expr.Permissive = true
+		case *ast.InExpr:
+			expr.Permissive = true
+		case *ast.BetweenExpr:
+			expr.Permissive = true
+		case *ast.MatchExpr:
+			expr.Permissive = true
+		case *ast.CaseExpr:
+			expr.Permissive = true
+		case *ast.Call:
+			expr.Permissive = true
+		case *ast.AggregateCall:
+			expr.Permissive = true
+		case *ast.WindowCall:
+			expr.Permissive = true
+		case *ast.SetReturningCall:
+			expr.Permissive = true
+		}
+
+		return true
+	})
+}
+
 func executeSelectStatement(session *Session, s *ast.SelectStatement, data map[string]interface{}) (*Results, error) {
+	limit, err := evalLimit(s.Limit, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.With != nil {
+		restore, err := prepareWith(session, s.With, data)
+		defer restore()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Compound != nil {
+		results, err := executeCompoundStatement(session, s.Compound, data)
+		if err != nil {
+			return nil, err
+		}
+		return limitResults(results, limit), nil
+	}
+
 	prepareSubselects(session, s.SelectClause)
 	if s.WhereClause != nil {
 		prepareSubselects(session, s.WhereClause)
 	}
 
-	iterators, err := getResourceIterators(session, s.FromClause.Resources)
+	if session.options.Permissive {
+		preparePermissive(s.SelectClause)
+		if s.WhereClause != nil {
+			preparePermissive(s.WhereClause)
+		}
+	}
+
+	for _, lateral := range s.FromClause.Laterals {
+		if lateral.Call != nil {
+			prepareSubselects(session, lateral.Call)
+			if session.options.Permissive {
+				preparePermissive(lateral.Call)
+			}
+			session.fold(lateral.Call, data)
+		}
+	}
+
+	session.fold(s.SelectClause, data)
+	if s.WhereClause != nil {
+		session.fold(s.WhereClause, data)
+	}
+
+	iterators, err := getResourceIterators(session, s.FromClause.Resources, data)
 	if err != nil {
 		return nil, err
 	}
 
+	// the columns of FROM items that are known before their rows are seen
+	columns := make(map[string][]string)
+	for _, iterator := range iterators {
+		if iterator, ok := iterator.(*ResultIterator); ok {
+			columns[iterator.name] = iterator.data.Headers
+		}
+	}
+
 	for _, subselect := range s.FromClause.Subselects {
 		results, err := executeSelectStatement(session, subselect.Select, data)
 		if err != nil {
 			return nil, err
 		}
 		iterators = append(iterators, &ResultIterator{name: subselect.Alias, data: results})
+		columns[subselect.Alias] = results.Headers
+	}
+
+	aggregates, err := prepareAggregates(s)
+	if err != nil {
+		return nil, err
 	}
 
+	windows, err := prepareWindows(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var join joiner.Iterator = joiner.NewInnerJoin(iterators)
+	if len(iterators) == 0 {
+		// a FROM clause of only functions evaluates them once
+		join = joiner.NewTupleIterator(joiner.Tuple{})
+	}
+
+	var laterals []*joiner.LateralJoin
+	for _, lateral := range s.FromClause.Laterals {
+		if lateral.Call != nil && len(lateral.Call.Columns()) > 1 {
+			columns[lateral.Alias] = lateral.Call.Columns()
+		}
+
+		lateralJoin := joiner.NewLateralJoin(join, lateralIterator(session, lateral, data, columns))
+		laterals = append(laterals, lateralJoin)
+		join = lateralJoin
+	}
+
+	stars := hasStars(s.SelectClause)
+
 	results := &Results{}
-	innerJoin := joiner.NewInnerJoin(iterators)
+	var items []map[string]interface{}
 	for {
-		if !innerJoin.HasNext() {
+		if !join.HasNext() {
 			break
 		}
 
-		item := make(joiner.Tuple).Merge(data, innerJoin.Next())
+		item := make(joiner.Tuple).Merge(data, join.Next())
 
 		// Filter
 		if s.WhereClause != nil && s.WhereClause.Condition != nil {
@@ -214,29 +931,66 @@ func executeSelectStatement(session *Session, s *ast.SelectStatement, data map[s
 			}
 		}
 
-		row := &Row{}
-		results.Rows = append(results.Rows, row)
+		// Aggregate
+		if len(aggregates) > 0 {
+			for _, aggregate := range aggregates {
+				if err := aggregate.Step(item); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		// window functions, and the columns of stars, are evaluated once
+		// every row has been seen
+		if len(windows) > 0 || stars {
+			items = append(items, item)
+			continue
+		}
 
 		// Extract
-		for _, expr := range s.SelectClause.Expressions {
-			evaled, err := expr.Condition.Eval(item)
+		row, err := evalRow(s.SelectClause, item)
+		if err != nil {
+			return nil, err
+		}
+		results.Rows = append(results.Rows, row)
+	}
+
+	for _, lateral := range laterals {
+		if err := lateral.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// aggregates produce a single row, evaluated once every row has been seen
+	if len(aggregates) > 0 {
+		if len(windows) > 0 {
+			// window functions are evaluated over the aggregated row
+			items = []map[string]interface{}{data}
+		} else {
+			row, err := evalRow(s.SelectClause, data)
 			if err != nil {
 				return nil, err
 			}
+			results.Rows = append(results.Rows, row)
+		}
+	}
 
-			if subres, ok := evaled.(*Results); ok {
-				if expr.Alias == "" {
-					expr.Alias = subres.Headers[0]
-				}
-				evaled = subres.Rows[0].Columns[0]
-			}
+	clause := s.SelectClause
+	if stars {
+		clause = expandStars(s, columns, items)
+	}
 
-			row.Columns = append(row.Columns, evaled)
+	if len(windows) > 0 || stars {
+		rows, err := evalRows(clause, windows, items)
+		if err != nil {
+			return nil, err
 		}
+		results.Rows = append(results.Rows, rows...)
 	}
 
 	// set headers
-	for _, expr := range s.SelectClause.Expressions {
+	for _, expr := range clause.Expressions {
 		alias := expr.Alias
 		if alias == "" {
 			alias = "?column?"
@@ -244,5 +998,39 @@ func executeSelectStatement(session *Session, s *ast.SelectStatement, data map[s
 		results.Headers = append(results.Headers, alias)
 	}
 
-	return results, nil
+	return limitResults(results, limit), nil
+}
+
+// evalLimit returns the maximum number of rows of a statement, or -1 if it
+// has no LIMIT clause.
+func evalLimit(limit *ast.LimitClause, data map[string]interface{}) (int64, error) {
+	if limit == nil {
+		return -1, nil
+	}
+	if limit.Param == nil {
+		return limit.Count, nil
+	}
+
+	val, err := limit.Param.Eval(data)
+	if err != nil {
+		return 0, err
+	}
+
+	count, ok := val.(int64)
+	if !ok {
+		return 0, &ast.EvalError{Offset: limit.Param.Offset, Msg: fmt.Sprintf("LIMIT expects an integer, got %v", ast.TypeOf(val))}
+	}
+	if count < 0 {
+		return 0, &ast.EvalError{Offset: limit.Param.Offset, Msg: "LIMIT must not be negative"}
+	}
+
+	return count, nil
+}
+
+// limitResults truncates results to at most limit rows, unless limit is -1.
+func limitResults(results *Results, limit int64) *Results {
+	if limit >= 0 && int64(len(results.Rows)) > limit {
+		results.Rows = results.Rows[:limit]
+	}
+	return results
 }
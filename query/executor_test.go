@@ -0,0 +1,434 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+// testResources are the resources served by the test API server, by kind.
+var testResources = map[string][]map[string]interface{}{
+	"pods": {
+		testObject("Pod", "a", "web-1", map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+			"spec":     map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "web", "image": "nginx:1.13"}}},
+		}),
+		testObject("Pod", "a", "web-2", map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+			"spec":     map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "web", "image": "nginx:1.13"}, map[string]interface{}{"name": "log", "image": "fluentd"}}},
+		}),
+		testObject("Pod", "b", "db-1", map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "db"}},
+			"spec":     map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "db", "image": "postgres:10"}}},
+		}),
+	},
+	"secrets": {
+		testObject("Secret", "a", "creds", map[string]interface{}{
+			"data": map[string]interface{}{"password": "hunter2"},
+		}),
+	},
+}
+
+// testObject returns a resource with a kind, namespace and name, merged with
+// fields.
+func testObject(kind, namespace, name string, fields map[string]interface{}) map[string]interface{} {
+	metadata := map[string]interface{}{"namespace": namespace, "name": name}
+	if m, ok := fields["metadata"].(map[string]interface{}); ok {
+		for k, v := range m {
+			metadata[k] = v
+		}
+	}
+
+	object := map[string]interface{}{"apiVersion": "v1", "kind": kind}
+	for k, v := range fields {
+		object[k] = v
+	}
+	object["metadata"] = metadata
+
+	return object
+}
+
+// testAPI is a fake Kubernetes API server, serving testResources. It records
+// the paths of the requests made to it.
+var testAPI struct {
+	*httptest.Server
+	paths []string
+}
+
+func TestMain(m *testing.M) {
+	testAPI.Server = httptest.NewServer(http.HandlerFunc(serveTestResources))
+	code := m.Run()
+	testAPI.Close()
+
+	os.Exit(code)
+}
+
+// serveTestResources lists the testResources of a kind, either from every
+// namespace, /api/v1/{kind}, or from one, /api/v1/namespaces/{namespace}/{kind}.
+func serveTestResources(w http.ResponseWriter, r *http.Request) {
+	testAPI.paths = append(testAPI.paths, r.URL.Path)
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+	namespace := ""
+	if len(parts) == 3 && parts[0] == "namespaces" {
+		namespace, parts = parts[1], parts[2:]
+	}
+
+	objects, ok := testResources[parts[0]]
+	if len(parts) != 1 || !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	items := []interface{}{}
+	for _, object := range objects {
+		if namespace == "" || object["metadata"].(map[string]interface{})["namespace"] == namespace {
+			items = append(items, object)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"metadata":   map[string]interface{}{},
+		"items":      items,
+	})
+}
+
+// prepareTest prepares a query against the test API server.
+func prepareTest(t *testing.T, query string, options Options) *Prepared {
+	t.Helper()
+
+	prepared, err := Prepare(&rest.Config{Host: testAPI.URL}, query, options)
+	if err != nil {
+		t.Fatalf("%q: %v", query, err)
+	}
+	return prepared
+}
+
+// executeTest executes a query against the test API server, returning its
+// results or error.
+func executeTest(t *testing.T, query string, params ...interface{}) (*Results, error) {
+	t.Helper()
+
+	testAPI.paths = nil
+	return prepareTest(t, query, Options{}).Execute(params...)
+}
+
+// column returns the values of a column of results.
+func column(results *Results, i int) []interface{} {
+	var values []interface{}
+	for _, row := range results.Rows {
+		values = append(values, row.Columns[i])
+	}
+	return values
+}
+
+// sortedColumn returns the values of a column of results, sorted by their
+// string representation, for results whose order isn't defined.
+func sortedColumn(results *Results, i int) []interface{} {
+	values := column(results, i)
+	sort.Slice(values, func(i, j int) bool {
+		return fmt.Sprint(values[i]) < fmt.Sprint(values[j])
+	})
+	return values
+}
+
+// rows returns the columns of each row of results.
+func rows(results *Results) [][]interface{} {
+	var values [][]interface{}
+	for _, row := range results.Rows {
+		values = append(values, row.Columns)
+	}
+	return values
+}
+
+// queryTest is a query and either the rows, in order, or the error it's
+// expected to return. Headers are only compared when set.
+type queryTest struct {
+	query   string
+	headers []string
+	rows    [][]interface{}
+	err     string
+}
+
+// testQueries prepares and executes each query against the test API server
+// and compares its rows or error.
+func testQueries(t *testing.T, tests []queryTest) {
+	t.Helper()
+
+	for _, test := range tests {
+		var results *Results
+		prepared, err := Prepare(&rest.Config{Host: testAPI.URL}, test.query, Options{})
+		if err == nil {
+			results, err = prepared.Execute()
+		}
+
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: got error %v, expected %q", test.query, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", test.query, err)
+		default:
+			if test.headers != nil && !reflect.DeepEqual(results.Headers, test.headers) {
+				t.Errorf("%q: got headers %q, expected %q", test.query, results.Headers, test.headers)
+			}
+			if values := rows(results); !reflect.DeepEqual(values, test.rows) {
+				t.Errorf("%q: got %#v, expected %#v", test.query, values, test.rows)
+			}
+		}
+	}
+}
+
+func TestExecuteNamespaces(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []interface{}
+		paths    []string
+	}{
+		{
+			query:    "select pods->metadata->name from pods namespace a",
+			expected: []interface{}{"web-1", "web-2"},
+			paths:    []string{"/api/v1/namespaces/a/pods"},
+		},
+		{
+			query:    "select pods->metadata->name from pods namespace a union select pods->metadata->name from pods namespace b",
+			expected: []interface{}{"db-1", "web-1", "web-2"},
+			paths:    []string{"/api/v1/namespaces/a/pods", "/api/v1/namespaces/b/pods"},
+		},
+		{
+			query:    "select pods->metadata->name from pods except select pods->metadata->name from pods namespace a",
+			expected: []interface{}{"db-1"},
+			paths:    []string{"/api/v1/pods", "/api/v1/namespaces/a/pods"},
+		},
+		{
+			query:    "select (select count(*) from pods as q) from pods namespace b",
+			expected: []interface{}{int64(3)},
+			paths:    []string{"/api/v1/namespaces/b/pods", "/api/v1/pods"},
+		},
+		{
+			// resources are listed once per namespace for each execution
+			query:    "select pods->metadata->name from pods namespace a union all select pods->metadata->name from pods namespace a",
+			expected: []interface{}{"web-1", "web-1", "web-2", "web-2"},
+			paths:    []string{"/api/v1/namespaces/a/pods"},
+		},
+	}
+
+	for _, test := range tests {
+		results, err := executeTest(t, test.query)
+		if err != nil {
+			t.Errorf("%q: %v", test.query, err)
+			continue
+		}
+
+		if values := sortedColumn(results, 0); !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("%q: got %#v, expected %#v", test.query, values, test.expected)
+		}
+		if !reflect.DeepEqual(testAPI.paths, test.paths) {
+			t.Errorf("%q: requested %q, expected %q", test.query, testAPI.paths, test.paths)
+		}
+	}
+}
+
+func TestExecuteSecrets(t *testing.T) {
+	results, err := executeTest(t, "select secrets->data->password, upper(secrets->data->password) from secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := fmt.Sprint(results.Rows[0].Columns); strings.Contains(out, "hunter2") || strings.Contains(out, "HUNTER2") {
+		t.Errorf("secret is %v", out)
+	}
+
+	_, err = executeTest(t, "select age(secrets->data->password) from secrets")
+	if err == nil || strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("got error %v, expected an error without the secret", err)
+	}
+
+	results, err = prepareTest(t, "select secrets->data->password from secrets", Options{ShowSecrets: true}).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := column(results, 0); !reflect.DeepEqual(values, []interface{}{"hunter2"}) {
+		t.Errorf("shown secret is %#v", values)
+	}
+}
+
+func TestExecuteLateral(t *testing.T) {
+	testQueries(t, []queryTest{
+		{
+			query: "select x->name, pods->metadata->name from pods, unnest(pods->spec->containers) as x",
+			rows:  [][]interface{}{{"web", "web-1"}, {"web", "web-2"}, {"log", "web-2"}, {"db", "db-1"}},
+		},
+		{
+			query: "select q->name, pods->metadata->name from pods namespace a, lateral (select x->name as name from unnest(pods->spec->containers) as x) as q",
+			rows:  [][]interface{}{{"web", "web-1"}, {"web", "web-2"}, {"log", "web-2"}},
+		},
+		{
+			query: "select q->name from pods, lateral (select x->name as name from unnest(pods->spec->containers) as x where x->name = 'log') as q",
+			rows:  [][]interface{}{{"log"}},
+		},
+		{
+			query: "select x->value, x->ordinality from unnest(['a', 'b']) with ordinality as x",
+			rows:  [][]interface{}{{"a", int64(1)}, {"b", int64(2)}},
+		},
+		{
+			query: "select e->key, e->value from json_each({'a': 1, 'b': 2}) as e",
+			rows:  [][]interface{}{{"a", int64(1)}, {"b", int64(2)}},
+		},
+		{
+			query: "select x from unnest(1) as x",
+			err:   "unnest() argument 1 expects array, got integer",
+		},
+	})
+}
+
+func TestExecuteCommonTableExpressions(t *testing.T) {
+	testQueries(t, []queryTest{
+		{
+			query: "with q as (select pods->metadata->name as name from pods namespace a) select q->name from q",
+			rows:  [][]interface{}{{"web-1"}, {"web-2"}},
+		},
+		{
+			query: "with recursive t as (select 1 as n from unnest([1]) as u union all select t->n + 1 from t where t->n < 5) select t->n from t",
+			rows:  [][]interface{}{{int64(1)}, {int64(2)}, {int64(3)}, {int64(4)}, {int64(5)}},
+		},
+		{
+			// UNION discards rows already produced, ending the recursion
+			query: "with recursive t as (select 1 as n from unnest([1]) as u union select t->n from t) select t->n from t",
+			rows:  [][]interface{}{{int64(1)}},
+		},
+		{
+			query: "with recursive t as (select 1 as n from unnest([1]) as u union all select t->n + 1 from t where t->n < 1000) select count(*), max(t->n) from t",
+			rows:  [][]interface{}{{int64(1000), int64(1000)}},
+		},
+		{
+			query: "with recursive t as (select 1 as n from unnest([1]) as u union all select t->n + 1 from t where t->n < 1001) select count(*) from t",
+			err:   "recursive query t exceeded 1000 iterations",
+		},
+		{
+			query: "with recursive t as (select 1 as n from unnest([1]) as u union all select t->n + 1 from t) select count(*) from t",
+			err:   "recursive query t exceeded 1000 iterations",
+		},
+	})
+}
+
+func TestExecuteSetOperations(t *testing.T) {
+	testQueries(t, []queryTest{
+		{
+			query: "select v from unnest([1, 2, 2, 3]) v union select v from unnest([3, 4]) v",
+			rows:  [][]interface{}{{int64(1)}, {int64(2)}, {int64(3)}, {int64(4)}},
+		},
+		{
+			query: "select v from unnest([1, 2, 2, 3]) v union all select v from unnest([3, 4]) v",
+			rows:  [][]interface{}{{int64(1)}, {int64(2)}, {int64(2)}, {int64(3)}, {int64(3)}, {int64(4)}},
+		},
+		{
+			query: "select v from unnest([1, 2, 2, 3]) v intersect select v from unnest([2, 3, 4]) v",
+			rows:  [][]interface{}{{int64(2)}, {int64(3)}},
+		},
+		{
+			query: "select v from unnest([1, 2, 2, 3]) v intersect all select v from unnest([2, 2, 2]) v",
+			rows:  [][]interface{}{{int64(2)}, {int64(2)}},
+		},
+		{
+			query: "select v from unnest([1, 2, 2, 3]) v except select v from unnest([3]) v",
+			rows:  [][]interface{}{{int64(1)}, {int64(2)}},
+		},
+		{
+			query: "select v from unnest([1, 2, 2, 3]) v except all select v from unnest([2]) v",
+			rows:  [][]interface{}{{int64(1)}, {int64(2)}, {int64(3)}},
+		},
+		{
+			query: "select v from unnest([1]) v union select v, v from unnest([1]) v",
+			err:   "each UNION query must have the same number of columns",
+		},
+	})
+}
+
+func TestExecuteWindows(t *testing.T) {
+	testQueries(t, []queryTest{
+		{
+			query: "select pods->metadata->name, row_number() over (order by pods->metadata->name) from pods",
+			rows:  [][]interface{}{{"web-1", int64(2)}, {"web-2", int64(3)}, {"db-1", int64(1)}},
+		},
+		{
+			query: "select row_number() over (partition by pods->metadata->namespace order by pods->metadata->name desc), count(*) over (partition by pods->metadata->namespace) from pods",
+			rows:  [][]interface{}{{int64(2), int64(2)}, {int64(1), int64(2)}, {int64(1), int64(1)}},
+		},
+		{
+			query: "select sum(v) over (order by v rows between 1 preceding and current row), lag(v) over (order by v), lead(v, 1, 0) over (order by v) from unnest([1, 2, 3]) v",
+			rows:  [][]interface{}{{int64(1), nil, int64(2)}, {int64(3), int64(1), int64(3)}, {int64(5), int64(2), int64(0)}},
+		},
+		{
+			query: "select rank() over (order by v), dense_rank() over (order by v) from unnest([1, 1, 2]) v",
+			rows:  [][]interface{}{{int64(1), int64(1)}, {int64(1), int64(1)}, {int64(3), int64(2)}},
+		},
+	})
+}
+
+func TestExecuteStars(t *testing.T) {
+	db := testResources["pods"][2]
+
+	testQueries(t, []queryTest{
+		{
+			query:   "select * from pods namespace b",
+			headers: []string{"apiVersion", "kind", "metadata", "spec"},
+			rows:    [][]interface{}{{"v1", "Pod", db["metadata"], db["spec"]}},
+		},
+		{
+			query:   "select pods.* from pods namespace b",
+			headers: []string{"apiVersion", "kind", "metadata", "spec"},
+			rows:    [][]interface{}{{"v1", "Pod", db["metadata"], db["spec"]}},
+		},
+		{
+			query:   "select q.*, 1 as one from (select pods->metadata->name as name, pods->metadata->namespace as ns from pods namespace b) as q",
+			headers: []string{"name", "ns", "one"},
+			rows:    [][]interface{}{{"db-1", "b", int64(1)}},
+		},
+		{
+			query:   "select * from (select pods->metadata->name as name from pods namespace b) as q, unnest([1, 2]) as x",
+			headers: []string{"name", "x"},
+			rows:    [][]interface{}{{"db-1", int64(1)}, {"db-1", int64(2)}},
+		},
+		{
+			query:   "select x.* from unnest([{'a': 1, 'b': 2}, {'b': 3, 'c': 4}]) as x",
+			headers: []string{"a", "b", "c"},
+			rows:    [][]interface{}{{int64(1), int64(2), nil}, {nil, int64(3), int64(4)}},
+		},
+	})
+}
+
+func TestExecuteLimit(t *testing.T) {
+	testQueries(t, []queryTest{
+		{
+			query: "select pods->metadata->name from pods limit 2",
+			rows:  [][]interface{}{{"web-1"}, {"web-2"}},
+		},
+		{
+			query: "select pods->metadata->name from pods limit 0",
+		},
+		{
+			query: "select v from unnest([1, 2]) v union all select v from unnest([3, 4]) v limit 3",
+			rows:  [][]interface{}{{int64(1)}, {int64(2)}, {int64(3)}},
+		},
+		{
+			query: "select (select v from unnest([1, 2]) v limit 1) from pods namespace b",
+			rows:  [][]interface{}{{int64(1)}},
+		},
+		{
+			query: "with q as (select pods->metadata->name as name from pods limit 1) select q->name from q",
+			rows:  [][]interface{}{{"web-1"}},
+		},
+	})
+}
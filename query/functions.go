@@ -0,0 +1,82 @@
+package query
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/saracen/kubeql/query/ast"
+	"github.com/saracen/kubeql/query/lexer"
+)
+
+// RegisterFunction registers a scalar function written in Go, making it
+// callable from queries. Functions declared Deterministic are evaluated once
+// when called with constant arguments, and their results are cached for
+// repeated arguments within a query.
+func RegisterFunction(fn *ast.Function) error {
+	if err := checkFunctionName(fn.Name); err != nil {
+		return err
+	}
+	if err := checkSignature(fn.Name, &fn.Signature); err != nil {
+		return err
+	}
+	if fn.Eval == nil {
+		return fmt.Errorf("function %v() has no Eval", fn.Name)
+	}
+	if ast.LookupAggregate(fn.Name) != nil {
+		return fmt.Errorf("function %v() is already registered as an aggregate function", fn.Name)
+	}
+	if ast.LookupWindowFunction(fn.Name) != nil {
+		return fmt.Errorf("function %v() is already registered as a window function", fn.Name)
+	}
+
+	ast.RegisterFunction(fn)
+
+	return nil
+}
+
+// RegisterAggregate registers an aggregate function written in Go. A new
+// Aggregator is created for each query, and stepped through every row.
+func RegisterAggregate(fn *ast.AggregateFunction) error {
+	if err := checkFunctionName(fn.Name); err != nil {
+		return err
+	}
+	if err := checkSignature(fn.Name, &fn.Signature); err != nil {
+		return err
+	}
+	if fn.New == nil {
+		return fmt.Errorf("aggregate function %v() has no New", fn.Name)
+	}
+	if ast.LookupFunction(fn.Name) != nil {
+		return fmt.Errorf("aggregate function %v() is already registered as a function", fn.Name)
+	}
+	if ast.LookupWindowFunction(fn.Name) != nil {
+		return fmt.Errorf("aggregate function %v() is already registered as a window function", fn.Name)
+	}
+
+	ast.RegisterAggregate(fn)
+
+	return nil
+}
+
+// checkFunctionName ensures a function name is scanned as an identifier, and
+// not a keyword.
+func checkFunctionName(name string) error {
+	s := lexer.NewScanner(bytes.NewBufferString(name))
+
+	if token, _, text := s.Scan(); token != lexer.Ident || text != name || s.Peek() != lexer.EOF {
+		return fmt.Errorf("invalid function name %q", name)
+	}
+
+	return nil
+}
+
+func checkSignature(name string, sig *ast.Signature) error {
+	switch {
+	case sig.MinArgs < 0 || sig.MinArgs > len(sig.Args):
+		return fmt.Errorf("function %v() requires %v arguments, but declares %v", name, sig.MinArgs, len(sig.Args))
+	case sig.Variadic && len(sig.Args) == 0:
+		return fmt.Errorf("variadic function %v() declares no arguments", name)
+	}
+
+	return nil
+}
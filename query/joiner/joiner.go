@@ -20,3 +20,28 @@ type Iterator interface {
 type Joiner interface {
 	Iterator
 }
+
+// TupleIterator iterates a slice of tuples.
+type TupleIterator struct {
+	tuples []Tuple
+	idx    int
+}
+
+func NewTupleIterator(tuples ...Tuple) *TupleIterator {
+	return &TupleIterator{tuples: tuples}
+}
+
+func (i *TupleIterator) HasNext() bool {
+	return i.idx < len(i.tuples)
+}
+
+func (i *TupleIterator) Next() Tuple {
+	if i.idx == len(i.tuples) {
+		i.idx = 0
+	}
+
+	idx := i.idx
+	i.idx++
+
+	return i.tuples[idx]
+}
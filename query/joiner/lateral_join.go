@@ -0,0 +1,45 @@
+package joiner
+
+// LateralJoin joins each tuple of an iterator with the tuples of an iterator
+// created from it, such as the elements of an array within the tuple.
+type LateralJoin struct {
+	Joiner
+
+	left  Iterator
+	right func(Tuple) (Iterator, error)
+
+	current Tuple
+	iter    Iterator
+	err     error
+}
+
+func NewLateralJoin(left Iterator, right func(Tuple) (Iterator, error)) *LateralJoin {
+	return &LateralJoin{left: left, right: right}
+}
+
+func (j *LateralJoin) HasNext() bool {
+	for j.err == nil {
+		if j.iter != nil && j.iter.HasNext() {
+			return true
+		}
+
+		if !j.left.HasNext() {
+			return false
+		}
+
+		j.current = j.left.Next()
+		j.iter, j.err = j.right(j.current)
+	}
+
+	return false
+}
+
+func (j *LateralJoin) Next() Tuple {
+	return make(Tuple).Merge(j.current, j.iter.Next())
+}
+
+// Err returns the error that stopped the join, if creating an iterator
+// failed.
+func (j *LateralJoin) Err() error {
+	return j.err
+}
@@ -18,21 +18,30 @@ const (
 	Ident
 
 	Comma
+	Colon
 	Arrow
 	OpenParenthesis
 	CloseParenthesis
+	OpenBrace
+	CloseBrace
+	OpenBracket
+	CloseBracket
 	String
 	Integer
 	Float
 	Dot
+	Parameter
 
 	And
 	Or
+	Not
+	Concat
 
 	Add
 	Subtract
 	Multiply
 	Divide
+	Modulo
 	True
 	False
 
@@ -43,26 +52,70 @@ const (
 	GreaterThan
 	GreaterThanEqual
 
+	In
+	Between
+	Like
+	ILike
+	Match
+	IMatch
+	NotMatch
+	NotIMatch
+
+	Contains
+	ContainedBy
+	HasKey
+	HasAnyKey
+	HasAllKeys
+
 	Select
 	From
 	As
 	Namespace
 	Where
-
-	JsonPath
-	Jq
+	With
+	Lateral
+	Recursive
+	Union
+	Intersect
+	Except
+	All
+	Over
+	Limit
+
+	Case
+	When
+	Then
+	Else
+	End
 )
 
+// IsKeyword reports whether a token is a keyword. Keywords can still be used as
+// keys in path expressions, such as metadata->namespace.
+func (t TokenType) IsKeyword() bool {
+	switch t {
+	case And, Or, Not, In, Between, Like, ILike, True, False,
+		Select, From, As, Namespace, Where, With, Lateral, Recursive, Union,
+		Intersect, Except, All, Over, Limit,
+		Case, When, Then, Else, End:
+		return true
+	}
+	return false
+}
+
 type Scanner struct {
 	r *bufio.Reader
 
 	idx, runeSize int
 	buf           bytes.Buffer
 	next          struct {
-		token  TokenType
-		text   string
-		offset int
+		token         TokenType
+		text          string
+		start, offset int
 	}
+
+	// start, tokenStart are the offsets of the start of the last scanned
+	// token, and of the token being scanned
+	start, tokenStart int
 }
 
 func NewScanner(r io.Reader) *Scanner {
@@ -74,18 +127,32 @@ func NewScanner(r io.Reader) *Scanner {
 
 func (s *Scanner) Scan() (TokenType, int, string) {
 	token, offset, text := s.next.token, s.next.offset, s.next.text
+	s.start = s.next.start
 
 	s.next.token, s.next.offset, s.next.text = s.scan(), s.idx, s.buf.String()
+	s.next.start = s.tokenStart
 
 	return token, offset, text
 }
 
+// Start returns the offset of the start of the token last returned by Scan,
+// where Scan's offset is that of its end.
+func (s *Scanner) Start() int {
+	return s.start
+}
+
 func (s *Scanner) Peek() TokenType {
 	return s.next.token
 }
 
+// PeekText returns the text of the next token.
+func (s *Scanner) PeekText() string {
+	return s.next.text
+}
+
 func (s *Scanner) scan() TokenType {
 	s.buf.Reset()
+	s.tokenStart = s.idx
 	r := s.read()
 
 	switch {
@@ -103,9 +170,14 @@ func (s *Scanner) scan() TokenType {
 		s.unread()
 		return s.scanString()
 
-	case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(s.peek())):
+	case unicode.IsDigit(r):
 		s.unread()
 		return s.scanNumber()
+
+	case r == '.' && unicode.IsDigit(s.peek()):
+		// the dot can't be unread once the next rune has been peeked at
+		s.buf.WriteRune(r)
+		return s.scanNumber()
 	}
 
 	s.buf.WriteRune(r)
@@ -114,6 +186,16 @@ func (s *Scanner) scan() TokenType {
 	case '.':
 		return Dot
 
+	case '$':
+		// positional parameters, $1
+		if !unicode.IsDigit(s.peek()) {
+			return Error
+		}
+		for unicode.IsDigit(s.peek()) {
+			s.buf.WriteRune(s.read())
+		}
+		return Parameter
+
 	case '-':
 		if s.peek() == '>' {
 			s.buf.WriteRune(s.read())
@@ -130,23 +212,73 @@ func (s *Scanner) scan() TokenType {
 	case '/':
 		return Divide
 
+	case '%':
+		return Modulo
+
 	case '=':
 		return Equal
 
+	case '|':
+		if s.peek() == '|' {
+			s.buf.WriteRune(s.read())
+			return Concat
+		}
+		return Error
+
+	case '~':
+		if s.peek() == '*' {
+			s.buf.WriteRune(s.read())
+			return IMatch
+		}
+		return Match
+
 	case '!':
-		if s.peek() == '=' {
+		switch s.peek() {
+		case '=':
 			s.buf.WriteRune(s.read())
 			return NotEqual
+
+		case '~':
+			s.buf.WriteRune(s.read())
+			if s.peek() == '*' {
+				s.buf.WriteRune(s.read())
+				return NotIMatch
+			}
+			return NotMatch
 		}
 		return Error
 
 	case '<':
-		if s.peek() == '=' {
+		switch s.peek() {
+		case '=':
 			s.buf.WriteRune(s.read())
 			return LessThanEqual
+
+		case '@':
+			s.buf.WriteRune(s.read())
+			return ContainedBy
 		}
 		return LessThan
 
+	case '@':
+		if s.peek() == '>' {
+			s.buf.WriteRune(s.read())
+			return Contains
+		}
+		return Error
+
+	case '?':
+		switch s.peek() {
+		case '|':
+			s.buf.WriteRune(s.read())
+			return HasAnyKey
+
+		case '&':
+			s.buf.WriteRune(s.read())
+			return HasAllKeys
+		}
+		return HasKey
+
 	case '>':
 		if s.peek() == '=' {
 			s.buf.WriteRune(s.read())
@@ -157,6 +289,21 @@ func (s *Scanner) scan() TokenType {
 	case ',':
 		return Comma
 
+	case ':':
+		return Colon
+
+	case '{':
+		return OpenBrace
+
+	case '}':
+		return CloseBrace
+
+	case '[':
+		return OpenBracket
+
+	case ']':
+		return CloseBracket
+
 	case '(':
 		return OpenParenthesis
 
@@ -195,6 +342,16 @@ func (s *Scanner) scanIdent() TokenType {
 		return Or
 	case "and":
 		return And
+	case "not":
+		return Not
+	case "in":
+		return In
+	case "between":
+		return Between
+	case "like":
+		return Like
+	case "ilike":
+		return ILike
 	case "select":
 		return Select
 	case "from":
@@ -205,14 +362,38 @@ func (s *Scanner) scanIdent() TokenType {
 		return Namespace
 	case "where":
 		return Where
+	case "with":
+		return With
+	case "lateral":
+		return Lateral
+	case "recursive":
+		return Recursive
+	case "union":
+		return Union
+	case "intersect":
+		return Intersect
+	case "except":
+		return Except
+	case "all":
+		return All
+	case "over":
+		return Over
+	case "limit":
+		return Limit
+	case "case":
+		return Case
+	case "when":
+		return When
+	case "then":
+		return Then
+	case "else":
+		return Else
+	case "end":
+		return End
 	case "true":
 		return True
 	case "false":
 		return False
-	case "jsonpath":
-		return JsonPath
-	case "jq":
-		return Jq
 	}
 
 	return Ident
@@ -254,7 +435,7 @@ func (s *Scanner) scanNumber() TokenType {
 		s.buf.WriteRune(s.read())
 	}
 
-	if _, err := strconv.Atoi(s.buf.String()); err == nil {
+	if !strings.Contains(s.buf.String(), ".") {
 		return Integer
 	}
 	if _, err := strconv.ParseFloat(s.buf.String(), 64); err == nil {
@@ -0,0 +1,119 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		input  string
+		tokens []TokenType
+		texts  []string
+	}{
+		{
+			input:  "SELECT a FROM b WHERE c LIMIT 10",
+			tokens: []TokenType{Select, Ident, From, Ident, Where, Ident, Limit, Integer},
+			texts:  []string{"SELECT", "a", "FROM", "b", "WHERE", "c", "LIMIT", "10"},
+		},
+		{
+			input:  "pods->metadata->'name'",
+			tokens: []TokenType{Ident, Arrow, Ident, Arrow, String},
+			texts:  []string{"pods", "->", "metadata", "->", "name"},
+		},
+		{
+			input:  `'it\'s' "a" ` + "`b`",
+			tokens: []TokenType{String, String, String},
+			texts:  []string{"it's", "a", "b"},
+		},
+		{
+			input:  "1 1.5 .5",
+			tokens: []TokenType{Integer, Float, Float},
+			texts:  []string{"1", "1.5", ".5"},
+		},
+		{
+			input:  "$1 $23 :name",
+			tokens: []TokenType{Parameter, Parameter, Colon, Ident},
+			texts:  []string{"$1", "$23", ":", "name"},
+		},
+		{
+			input:  "|| ~ ~* !~ !~* != <= >= < > =",
+			tokens: []TokenType{Concat, Match, IMatch, NotMatch, NotIMatch, NotEqual, LessThanEqual, GreaterThanEqual, LessThan, GreaterThan, Equal},
+		},
+		{
+			input:  "@> <@ ? ?| ?&",
+			tokens: []TokenType{Contains, ContainedBy, HasKey, HasAnyKey, HasAllKeys},
+		},
+		{
+			input:  "+ - * / %",
+			tokens: []TokenType{Add, Subtract, Multiply, Divide, Modulo},
+		},
+		{
+			input:  "{ } [ ] ( ) , .",
+			tokens: []TokenType{OpenBrace, CloseBrace, OpenBracket, CloseBracket, OpenParenthesis, CloseParenthesis, Comma, Dot},
+		},
+		{
+			input:  "with recursive union intersect except all lateral over",
+			tokens: []TokenType{With, Recursive, Union, Intersect, Except, All, Lateral, Over},
+		},
+		{
+			input:  "case when then else end and or not in between like ilike true false",
+			tokens: []TokenType{Case, When, Then, Else, End, And, Or, Not, In, Between, Like, ILike, True, False},
+		},
+		{
+			input:  "$ | @",
+			tokens: []TokenType{Error, Error, Error},
+		},
+	}
+
+	for _, test := range tests {
+		s := NewScanner(strings.NewReader(test.input))
+
+		for i, expected := range test.tokens {
+			token, _, text := s.Scan()
+			if token != expected {
+				t.Errorf("%q: token %d is %v, expected %v", test.input, i, token, expected)
+				break
+			}
+			if test.texts != nil && text != test.texts[i] {
+				t.Errorf("%q: token %d is %q, expected %q", test.input, i, text, test.texts[i])
+			}
+		}
+
+		if token, _, text := s.Scan(); token != EOF {
+			t.Errorf("%q: expected EOF, got %v (%q)", test.input, token, text)
+		}
+	}
+}
+
+func TestScanOffsets(t *testing.T) {
+	input := "select  jq(x, '.a')"
+	s := NewScanner(strings.NewReader(input))
+
+	expected := []struct {
+		start, end int
+	}{
+		{0, 6}, {8, 10}, {10, 11}, {11, 12}, {12, 13}, {14, 18}, {18, 19},
+	}
+
+	for i, offsets := range expected {
+		_, end, text := s.Scan()
+		if start := s.Start(); start != offsets.start || end != offsets.end {
+			t.Errorf("token %d (%q) is at %d-%d, expected %d-%d", i, text, start, end, offsets.start, offsets.end)
+		}
+	}
+}
+
+func TestIsKeyword(t *testing.T) {
+	for _, token := range []TokenType{Select, From, Namespace, Limit, Over, Case, True} {
+		if !token.IsKeyword() {
+			t.Errorf("%v isn't a keyword", token)
+		}
+	}
+
+	for _, token := range []TokenType{Ident, String, Arrow, Parameter} {
+		if token.IsKeyword() {
+			t.Errorf("%v is a keyword", token)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/saracen/kubeql/query/ast"
 	"github.com/saracen/kubeql/query/lexer"
@@ -18,6 +20,10 @@ type SelectStatement struct {
 type Parser struct {
 	s     *lexer.Scanner
 	input string
+
+	// Parameters are the parameters of the parsed query, in the order
+	// they're written
+	Parameters []*ast.Parameter
 }
 
 func NewStringParser(input string) *Parser {
@@ -36,9 +42,8 @@ func (p *Parser) Parse() (stmt *ast.SelectStatement, err error) {
 	statement := p.s.Peek()
 
 	switch statement {
-	case lexer.Select:
-		p.match(lexer.Select)
-		query := p.SelectStatement()
+	case lexer.Select, lexer.With:
+		query := p.Query()
 		p.match(lexer.EOF)
 
 		return query, nil
@@ -49,16 +54,162 @@ func (p *Parser) Parse() (stmt *ast.SelectStatement, err error) {
 }
 
 func (p *Parser) error(str string, offset int) {
-	panic(fmt.Sprintf("%v (offset: %v) (%v <)", str, offset, strconv.Quote(p.input[:offset])))
+	panic(sourceError(p.input, str, offset))
+}
+
+func sourceError(input string, str string, offset int) string {
+	return fmt.Sprintf("%v (offset: %v) (%v <)", str, offset, strconv.Quote(input[:offset]))
 }
 
 func (p *Parser) match(token lexer.TokenType) string {
+	text, _ := p.matchOffset(token)
+
+	return text
+}
+
+func (p *Parser) matchOffset(token lexer.TokenType) (string, int) {
 	t, offset, text := p.s.Scan()
 	if t != token {
 		p.error("unexpected token", offset)
 	}
 
-	return text
+	return text, offset
+}
+
+// isWord reports whether the next token is an identifier that's a keyword only
+// in context, such as ORDINALITY, so that it can still be used as a name.
+func (p *Parser) isWord(word string) bool {
+	return p.s.Peek() == lexer.Ident && strings.EqualFold(p.s.PeekText(), word)
+}
+
+func (p *Parser) matchWord(word string) int {
+	text, offset := p.matchOffset(lexer.Ident)
+	if !strings.EqualFold(text, word) {
+		p.error(fmt.Sprintf("expected %v, got %v", strings.ToUpper(word), text), offset)
+	}
+
+	return offset
+}
+
+// Query parses a SELECT statement, which can be preceded by a WITH clause,
+// combined with others by UNION, INTERSECT and EXCEPT, and followed by a LIMIT
+// clause.
+func (p *Parser) Query() *ast.SelectStatement {
+	var with *ast.WithClause
+	if p.s.Peek() == lexer.With {
+		with = p.WithClause()
+	}
+
+	query := p.CompoundStatement()
+	query.With = with
+
+	if p.s.Peek() == lexer.Limit {
+		query.Limit = p.LimitClause()
+	}
+
+	return query
+}
+
+// LimitClause parses LIMIT followed by an integer or a parameter.
+func (p *Parser) LimitClause() *ast.LimitClause {
+	p.match(lexer.Limit)
+
+	switch p.s.Peek() {
+	case lexer.Parameter, lexer.Colon:
+		return &ast.LimitClause{Param: p.Parameter()}
+	}
+
+	text, offset := p.matchOffset(lexer.Integer)
+	count, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		p.error("integer out of range", offset)
+	}
+
+	return &ast.LimitClause{Count: count}
+}
+
+// CompoundStatement parses statements combined by set operations, which are
+// evaluated left to right, except INTERSECT, which binds more tightly.
+func (p *Parser) CompoundStatement() *ast.SelectStatement {
+	lhs := p.IntersectStatement()
+	for p.s.Peek() == lexer.Union || p.s.Peek() == lexer.Except {
+		lhs = p.SetOperation(lhs, p.IntersectStatement)
+	}
+
+	return lhs
+}
+
+func (p *Parser) IntersectStatement() *ast.SelectStatement {
+	lhs := p.SimpleStatement()
+	for p.s.Peek() == lexer.Intersect {
+		lhs = p.SetOperation(lhs, p.SimpleStatement)
+	}
+
+	return lhs
+}
+
+func (p *Parser) SimpleStatement() *ast.SelectStatement {
+	p.match(lexer.Select)
+
+	return p.SelectStatement()
+}
+
+// SetOperation parses a set operator, an optional ALL, and the statement
+// that follows them.
+func (p *Parser) SetOperation(lhs *ast.SelectStatement, rhs func() *ast.SelectStatement) *ast.SelectStatement {
+	op, _, _ := p.s.Scan()
+
+	compound := &ast.CompoundStatement{Op: ast.Operator(op), Left: lhs}
+	if p.s.Peek() == lexer.All {
+		p.match(lexer.All)
+		compound.All = true
+	}
+	compound.Right = rhs()
+
+	return &ast.SelectStatement{Compound: compound}
+}
+
+func (p *Parser) WithClause() *ast.WithClause {
+	p.match(lexer.With)
+
+	with := &ast.WithClause{}
+	if p.s.Peek() == lexer.Recursive {
+		p.match(lexer.Recursive)
+		with.Recursive = true
+	}
+
+	with.Tables = append(with.Tables, p.CommonTableExpr(with.Recursive))
+	for p.s.Peek() == lexer.Comma {
+		p.match(lexer.Comma)
+		with.Tables = append(with.Tables, p.CommonTableExpr(with.Recursive))
+	}
+
+	return with
+}
+
+// CommonTableExpr parses a named statement of a WITH clause. A recursive
+// statement is split at its last UNION [ALL] into an initial statement and a
+// statement that references the results so far, and can't be limited.
+func (p *Parser) CommonTableExpr(recursive bool) *ast.CommonTableExpr {
+	cte := &ast.CommonTableExpr{Name: p.match(lexer.Ident)}
+
+	p.match(lexer.As)
+	p.match(lexer.OpenParenthesis)
+	cte.Select = p.CompoundStatement()
+
+	if compound := cte.Select.Compound; recursive && compound != nil && lexer.TokenType(compound.Op) == lexer.Union {
+		if p.s.Peek() == lexer.Limit {
+			_, offset := p.matchOffset(lexer.Limit)
+			p.error("LIMIT is not supported in a recursive query", offset)
+		}
+		cte.Select, cte.Recursive, cte.UnionAll = compound.Left, compound.Right, compound.All
+	} else if p.s.Peek() == lexer.Limit {
+		cte.Select.Limit = p.LimitClause()
+	}
+
+	p.match(lexer.CloseParenthesis)
+
+	return cte
 }
 
 func (p *Parser) SelectStatement() *ast.SelectStatement {
@@ -75,9 +226,7 @@ func (p *Parser) SelectStatement() *ast.SelectStatement {
 }
 
 func (p *Parser) Subselect() *ast.Subselect {
-	p.match(lexer.Select)
-
-	return &ast.Subselect{Select: p.SelectStatement()}
+	return &ast.Subselect{Select: p.Query()}
 }
 
 func (p *Parser) SelectClause() *ast.SelectClause {
@@ -97,30 +246,59 @@ func (p *Parser) FromClause() *ast.FromClause {
 	p.match(lexer.From)
 
 	from := &ast.FromClause{}
-
-	if p.s.Peek() == lexer.OpenParenthesis {
-		from.Subselects = []*ast.FromSubselect{p.FromSubselect()}
-	} else {
-		from.Resources = []*ast.FromResource{p.FromResource()}
-	}
+	p.FromItem(from)
 
 	for p.s.Peek() == lexer.Comma {
 		p.match(lexer.Comma)
+		p.FromItem(from)
+	}
 
+	return from
+}
+
+// FromItem parses a resource, subselect or set-returning function call, and
+// adds it to the FROM clause.
+func (p *Parser) FromItem(from *ast.FromClause) {
+	switch p.s.Peek() {
+	case lexer.OpenParenthesis:
+		subselect := p.FromSubselect()
+		from.Subselects = append(from.Subselects, subselect)
+		from.Aliases = append(from.Aliases, subselect.Alias)
+		return
+
+	case lexer.Lateral:
+		// set-returning function calls can reference the items before them
+		// with or without LATERAL
+		p.match(lexer.Lateral)
 		if p.s.Peek() == lexer.OpenParenthesis {
-			from.Subselects = append(from.Subselects, p.FromSubselect())
-		} else {
-			from.Resources = append(from.Resources, p.FromResource())
+			subselect := p.FromSubselect()
+			from.Laterals = append(from.Laterals, &ast.FromLateral{Alias: subselect.Alias, Select: subselect.Select})
+			from.Aliases = append(from.Aliases, subselect.Alias)
+			return
 		}
+
+		lateral := p.FromFunction(p.matchOffset(lexer.Ident))
+		from.Laterals = append(from.Laterals, lateral)
+		from.Aliases = append(from.Aliases, lateral.Alias)
+		return
 	}
 
-	return from
+	name, offset := p.matchOffset(lexer.Ident)
+	if p.s.Peek() == lexer.OpenParenthesis {
+		lateral := p.FromFunction(name, offset)
+		from.Laterals = append(from.Laterals, lateral)
+		from.Aliases = append(from.Aliases, lateral.Alias)
+		return
+	}
+
+	resource := p.FromResource(name)
+	from.Resources = append(from.Resources, resource)
+	from.Aliases = append(from.Aliases, resource.Alias)
 }
 
 func (p *Parser) FromSubselect() *ast.FromSubselect {
 	p.match(lexer.OpenParenthesis)
-	p.match(lexer.Select)
-	s := p.SelectStatement()
+	s := p.Query()
 	p.match(lexer.CloseParenthesis)
 
 	return &ast.FromSubselect{
@@ -129,9 +307,9 @@ func (p *Parser) FromSubselect() *ast.FromSubselect {
 	}
 }
 
-func (p *Parser) FromResource() *ast.FromResource {
+func (p *Parser) FromResource(kind string) *ast.FromResource {
 	resource := &ast.FromResource{Version: "v1"}
-	resource.Kind = p.match(lexer.Ident)
+	resource.Kind = kind
 
 	if p.s.Peek() == lexer.Divide {
 		p.match(lexer.Divide)
@@ -143,7 +321,12 @@ func (p *Parser) FromResource() *ast.FromResource {
 
 	if p.s.Peek() == lexer.Namespace {
 		p.match(lexer.Namespace)
-		resource.Namespace = p.match(lexer.Ident)
+		switch p.s.Peek() {
+		case lexer.Parameter, lexer.Colon:
+			resource.NamespaceParam = p.Parameter()
+		default:
+			resource.Namespace = p.match(lexer.Ident)
+		}
 	}
 
 	resource.Alias = p.AsAlias(resource.Kind, false, false)
@@ -151,6 +334,38 @@ func (p *Parser) FromResource() *ast.FromResource {
 	return resource
 }
 
+func (p *Parser) FromFunction(name string, offset int) *ast.FromLateral {
+	call := &ast.SetReturningCall{Name: name, Func: ast.LookupSetReturningFunction(name), Offset: offset}
+	if call.Func == nil {
+		p.error(fmt.Sprintf("unknown set-returning function %v()", name), offset)
+	}
+
+	p.match(lexer.OpenParenthesis)
+	call.Args = p.CallArguments()
+
+	if err := call.Func.CheckArity(len(call.Args)); err != nil {
+		p.error(err.Error(), offset)
+	}
+
+	if literal, ok := call.ProgramLiteral(); ok {
+		if err := call.Compile(); err != nil {
+			p.error(err.Error(), literal.Offset)
+		}
+	}
+
+	// ordinality isn't a keyword, so that it can name the column
+	if p.s.Peek() == lexer.With {
+		p.match(lexer.With)
+		p.matchWord("ordinality")
+		call.Ordinality = true
+	}
+
+	return &ast.FromLateral{
+		Alias: p.AsAlias(name, false, false),
+		Call:  call,
+	}
+}
+
 func (p *Parser) WhereClause() *ast.WhereClause {
 	where := &ast.WhereClause{}
 
@@ -160,33 +375,121 @@ func (p *Parser) WhereClause() *ast.WhereClause {
 	return where
 }
 
+// SelectExpression parses an expression and its alias, or * or alias.*,
+// which select the columns of every FROM item or of a single FROM item.
 func (p *Parser) SelectExpression() *ast.SelectExpression {
 	selectExpr := &ast.SelectExpression{}
 
-	selectExpr.Condition = p.Expression(1)
+	switch p.s.Peek() {
+	case lexer.Multiply:
+		_, offset := p.matchOffset(lexer.Multiply)
+		selectExpr.Star = &ast.Star{Offset: offset}
+
+		return selectExpr
+
+	case lexer.Ident:
+		name, offset := p.matchOffset(lexer.Ident)
+		if p.s.Peek() == lexer.Dot {
+			p.match(lexer.Dot)
+			p.match(lexer.Multiply)
+			selectExpr.Star = &ast.Star{Source: name, Offset: offset}
+
+			return selectExpr
+		}
+
+		selectExpr.Condition = p.BinaryExpression(p.IdentExpression(name, offset), 1)
+
+	default:
+		selectExpr.Condition = p.Expression(1)
+	}
 	selectExpr.Alias = p.AsAlias("", true, false)
 
 	return selectExpr
 }
 
 func (p *Parser) PathExpression() *ast.PathExpression {
-	var fields []string
+	path := &ast.PathExpression{}
 	for p.s.Peek() == lexer.Arrow {
 		p.match(lexer.Arrow)
+		path.Steps = append(path.Steps, p.PathStep())
+	}
 
-		switch p.s.Peek() {
-		case lexer.Ident:
-			fields = append(fields, p.match(lexer.Ident))
-		case lexer.String:
-			fields = append(fields, p.match(lexer.String))
-		case lexer.Integer:
-			fields = append(fields, p.match(lexer.Integer))
+	return path
+}
+
+func (p *Parser) PathStep() *ast.PathStep {
+	switch p.s.Peek() {
+	case lexer.Ident:
+		return &ast.PathStep{Key: p.match(lexer.Ident)}
+
+	case lexer.String:
+		return &ast.PathStep{Key: p.match(lexer.String)}
+
+	case lexer.Integer, lexer.Subtract, lexer.Colon:
+		return p.PathIndex()
+
+	case lexer.Multiply:
+		p.match(lexer.Multiply)
+		if p.s.Peek() == lexer.Multiply {
+			p.match(lexer.Multiply)
+			return &ast.PathStep{Kind: ast.PathRecursive}
+		}
+		return &ast.PathStep{Kind: ast.PathWildcard}
+
+	case lexer.OpenParenthesis:
+		p.match(lexer.OpenParenthesis)
+		expr := p.Expression(1)
+		p.match(lexer.CloseParenthesis)
+
+		return &ast.PathStep{Kind: ast.PathComputed, Expr: expr}
+	}
+
+	token, offset, text := p.s.Scan()
+	if token.IsKeyword() {
+		return &ast.PathStep{Key: text}
+	}
+	p.error("unexpected token in path expression", offset)
+
+	return nil
+}
+
+// PathIndex parses an array index, such as 1 or -1, or a slice, such as 1:3,
+// :-1 or 2:.
+func (p *Parser) PathIndex() *ast.PathStep {
+	var start *int
+	if p.s.Peek() != lexer.Colon {
+		start = p.PathInteger()
+		if p.s.Peek() != lexer.Colon {
+			return &ast.PathStep{Key: strconv.Itoa(*start)}
 		}
 	}
 
-	return &ast.PathExpression{
-		Fields: fields,
+	p.match(lexer.Colon)
+	step := &ast.PathStep{Kind: ast.PathSlice, Start: start}
+	if token := p.s.Peek(); token == lexer.Integer || token == lexer.Subtract {
+		step.End = p.PathInteger()
 	}
+
+	return step
+}
+
+func (p *Parser) PathInteger() *int {
+	negative := false
+	if p.s.Peek() == lexer.Subtract {
+		p.match(lexer.Subtract)
+		negative = true
+	}
+
+	text, offset := p.matchOffset(lexer.Integer)
+	i, err := strconv.Atoi(text)
+	if err != nil {
+		p.error(fmt.Sprintf("invalid index %v", text), offset)
+	}
+	if negative {
+		i = -i
+	}
+
+	return &i
 }
 
 func (p *Parser) AsAlias(def string, allowString, required bool) string {
@@ -207,23 +510,414 @@ func (p *Parser) AsAlias(def string, allowString, required bool) string {
 }
 
 func (p *Parser) Expression(precedence int) ast.Expr {
-	lhs := p.UnaryExpression()
+	return p.BinaryExpression(p.UnaryExpression(), precedence)
+}
 
+// BinaryExpression parses the operators and predicates following lhs that bind
+// at least as tightly as precedence.
+func (p *Parser) BinaryExpression(lhs ast.Expr, precedence int) ast.Expr {
 	for {
+		if p.isPredicate(p.s.Peek()) && ast.Operator(lexer.Equal).Precedence() >= precedence {
+			lhs = p.Predicate(lhs)
+			continue
+		}
+
 		op := ast.Operator(p.s.Peek())
-		if op.IsOperator() && op.Precedence() >= precedence {
-			p.match(lexer.TokenType(op))
-		} else {
+		if !op.IsOperator() || op.Precedence() < precedence {
 			break
 		}
+		_, offset := p.matchOffset(lexer.TokenType(op))
 
-		rhs := p.Expression(op.Precedence())
-		lhs = &ast.BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+		// operators are left-associative, so the right-hand side only
+		// binds operators of a higher precedence
+		rhs := p.Expression(op.Precedence() + 1)
+		lhs = &ast.BinaryExpr{Op: op, LHS: lhs, RHS: rhs, Offset: offset}
 	}
 
 	return lhs
 }
 
+func (p *Parser) isPredicate(token lexer.TokenType) bool {
+	switch token {
+	case lexer.Not, lexer.In, lexer.Between, lexer.Like, lexer.ILike,
+		lexer.Match, lexer.IMatch, lexer.NotMatch, lexer.NotIMatch:
+		return true
+	}
+
+	return false
+}
+
+// Predicate parses the IN, BETWEEN and pattern matching operators, which
+// share the precedence of comparisons. lhs is the expression being tested.
+func (p *Parser) Predicate(lhs ast.Expr) ast.Expr {
+	not := false
+	if p.s.Peek() == lexer.Not {
+		p.match(lexer.Not)
+		not = true
+
+		switch p.s.Peek() {
+		case lexer.In, lexer.Between, lexer.Like, lexer.ILike:
+		default:
+			_, offset, _ := p.s.Scan()
+			p.error("expected IN, BETWEEN, LIKE or ILIKE after NOT", offset)
+		}
+	}
+
+	token := p.s.Peek()
+	_, offset := p.matchOffset(token)
+
+	switch token {
+	case lexer.In:
+		in := &ast.InExpr{Expr: lhs, Not: not, Offset: offset}
+
+		p.match(lexer.OpenParenthesis)
+		in.List = append(in.List, p.Expression(1))
+		for p.s.Peek() == lexer.Comma {
+			p.match(lexer.Comma)
+			in.List = append(in.List, p.Expression(1))
+		}
+		p.match(lexer.CloseParenthesis)
+
+		return in
+
+	case lexer.Between:
+		// the bounds bind tighter than AND, which separates them
+		precedence := ast.Operator(lexer.Equal).Precedence() + 1
+
+		between := &ast.BetweenExpr{Expr: lhs, Not: not, Offset: offset}
+		between.Low = p.Expression(precedence)
+		p.match(lexer.And)
+		between.High = p.Expression(precedence)
+
+		return between
+	}
+
+	match := &ast.MatchExpr{Op: ast.Operator(token), Expr: lhs, Not: not, Offset: offset}
+	switch token {
+	case lexer.NotMatch:
+		match.Op, match.Not = ast.Operator(lexer.Match), true
+	case lexer.NotIMatch:
+		match.Op, match.Not = ast.Operator(lexer.IMatch), true
+	}
+
+	match.Pattern = p.Expression(ast.Operator(lexer.Equal).Precedence() + 1)
+
+	// literal patterns are compiled up front, so that invalid patterns are
+	// reported before any resources are fetched
+	if pattern, ok := match.Pattern.(*ast.String); ok {
+		if _, err := match.Regexp(pattern.Val); err != nil {
+			p.error(err.Error(), offset)
+		}
+	}
+
+	return match
+}
+
+func (p *Parser) CaseExpression() *ast.CaseExpr {
+	_, offset := p.matchOffset(lexer.Case)
+	expr := &ast.CaseExpr{Offset: offset}
+
+	if p.s.Peek() != lexer.When {
+		expr.Operand = p.Expression(1)
+	}
+
+	for {
+		p.match(lexer.When)
+		when := &ast.WhenClause{Condition: p.Expression(1)}
+		p.match(lexer.Then)
+		when.Result = p.Expression(1)
+
+		expr.Whens = append(expr.Whens, when)
+		if p.s.Peek() != lexer.When {
+			break
+		}
+	}
+
+	if p.s.Peek() == lexer.Else {
+		p.match(lexer.Else)
+		expr.Else = p.Expression(1)
+	}
+	p.match(lexer.End)
+
+	return expr
+}
+
+// ObjectLiteral parses an object literal. Keys are strings or identifiers.
+func (p *Parser) ObjectLiteral() *ast.ObjectLiteral {
+	p.match(lexer.OpenBrace)
+	obj := &ast.ObjectLiteral{}
+
+	for p.s.Peek() != lexer.CloseBrace {
+		if len(obj.Keys) > 0 {
+			p.match(lexer.Comma)
+		}
+
+		var key string
+		var offset int
+		switch p.s.Peek() {
+		case lexer.Ident:
+			key, offset = p.matchOffset(lexer.Ident)
+		default:
+			key, offset = p.matchOffset(lexer.String)
+		}
+
+		for _, existing := range obj.Keys {
+			if existing == key {
+				p.error(fmt.Sprintf("duplicate key %q in object", key), offset)
+			}
+		}
+		p.match(lexer.Colon)
+
+		obj.Keys = append(obj.Keys, key)
+		obj.Values = append(obj.Values, p.Expression(1))
+	}
+	p.match(lexer.CloseBrace)
+
+	return obj
+}
+
+// ArrayLiteral parses an array literal.
+func (p *Parser) ArrayLiteral() *ast.ArrayLiteral {
+	p.match(lexer.OpenBracket)
+	arr := &ast.ArrayLiteral{}
+
+	for p.s.Peek() != lexer.CloseBracket {
+		if len(arr.Elements) > 0 {
+			p.match(lexer.Comma)
+		}
+		arr.Elements = append(arr.Elements, p.Expression(1))
+	}
+	p.match(lexer.CloseBracket)
+
+	return arr
+}
+
+// Call parses a call to the named function or aggregate function.
+func (p *Parser) Call(name string, offset int) ast.Expr {
+	if fn := ast.LookupAggregate(name); fn != nil {
+		call := &ast.AggregateCall{Name: name, Func: fn, Offset: offset}
+
+		// count(*) counts every row
+		p.match(lexer.OpenParenthesis)
+		if p.s.Peek() == lexer.Multiply {
+			p.match(lexer.Multiply)
+			p.match(lexer.CloseParenthesis)
+		} else {
+			call.Args = p.CallArguments()
+		}
+
+		if err := fn.CheckArity(len(call.Args)); err != nil {
+			p.error(err.Error(), offset)
+		}
+
+		if p.s.Peek() == lexer.Over {
+			return p.Over(&ast.WindowCall{Name: name, Args: call.Args, Aggregate: fn, Offset: offset})
+		}
+
+		if p.s.Peek() == lexer.Arrow {
+			call.PathExpr = p.PathExpression()
+		}
+
+		return call
+	}
+
+	if fn := ast.LookupWindowFunction(name); fn != nil {
+		call := &ast.WindowCall{Name: name, Func: fn, Offset: offset}
+
+		p.match(lexer.OpenParenthesis)
+		call.Args = p.CallArguments()
+
+		if err := fn.CheckArity(len(call.Args)); err != nil {
+			p.error(err.Error(), offset)
+		}
+
+		if p.s.Peek() != lexer.Over {
+			p.error(fmt.Sprintf("window function %v() requires an OVER clause", name), offset)
+		}
+
+		return p.Over(call)
+	}
+
+	call := &ast.Call{Name: name, Func: ast.LookupFunction(name), Offset: offset}
+	if call.Func == nil {
+		p.error(fmt.Sprintf("unknown function %v()", name), offset)
+	}
+
+	p.match(lexer.OpenParenthesis)
+	call.Args = p.CallArguments()
+
+	if err := call.Func.CheckArity(len(call.Args)); err != nil {
+		p.error(err.Error(), offset)
+	}
+
+	// programs written as literals are compiled as they're parsed
+	if literal, ok := call.ProgramLiteral(); ok {
+		if err := call.Compile(); err != nil {
+			p.error(err.Error(), literal.Offset)
+		}
+	}
+
+	if p.s.Peek() == lexer.Arrow {
+		call.PathExpr = p.PathExpression()
+	}
+
+	return call
+}
+
+// Over parses the OVER clause of a window function call, and any path
+// expression following it.
+func (p *Parser) Over(call *ast.WindowCall) *ast.WindowCall {
+	p.match(lexer.Over)
+	call.Window = p.Window()
+
+	if p.s.Peek() == lexer.Arrow {
+		call.PathExpr = p.PathExpression()
+	}
+
+	return call
+}
+
+// Window parses the parenthesized PARTITION BY, ORDER BY and ROWS clauses of a
+// window. Their words aren't keywords, so that they can still name keys.
+func (p *Parser) Window() *ast.Window {
+	window := &ast.Window{}
+
+	p.match(lexer.OpenParenthesis)
+	if p.isWord("partition") {
+		p.matchWord("partition")
+		p.matchWord("by")
+
+		window.PartitionBy = append(window.PartitionBy, p.Expression(1))
+		for p.s.Peek() == lexer.Comma {
+			p.match(lexer.Comma)
+			window.PartitionBy = append(window.PartitionBy, p.Expression(1))
+		}
+	}
+
+	if p.isWord("order") {
+		p.matchWord("order")
+		p.matchWord("by")
+
+		window.OrderBy = append(window.OrderBy, p.OrderTerm())
+		for p.s.Peek() == lexer.Comma {
+			p.match(lexer.Comma)
+			window.OrderBy = append(window.OrderBy, p.OrderTerm())
+		}
+	}
+
+	if p.isWord("rows") {
+		p.matchWord("rows")
+		window.Frame = p.WindowFrame()
+	}
+	p.match(lexer.CloseParenthesis)
+
+	return window
+}
+
+func (p *Parser) OrderTerm() *ast.OrderTerm {
+	term := &ast.OrderTerm{Expr: p.Expression(1)}
+
+	switch {
+	case p.isWord("asc"):
+		p.matchWord("asc")
+
+	case p.isWord("desc"):
+		p.matchWord("desc")
+		term.Desc = true
+	}
+
+	return term
+}
+
+// WindowFrame parses the bounds of a ROWS frame. A frame with only a start
+// ends at the current row.
+func (p *Parser) WindowFrame() *ast.WindowFrame {
+	frame := &ast.WindowFrame{End: ast.FrameBound{Kind: ast.CurrentRow}}
+
+	between := p.s.Peek() == lexer.Between
+	if between {
+		p.match(lexer.Between)
+	}
+
+	var offset int
+	frame.Start, offset = p.FrameBound()
+	if frame.Start.Kind == ast.UnboundedFollowing {
+		p.error("frame start cannot be UNBOUNDED FOLLOWING", offset)
+	}
+
+	if between {
+		p.match(lexer.And)
+		frame.End, offset = p.FrameBound()
+		if frame.End.Kind == ast.UnboundedPreceding {
+			p.error("frame end cannot be UNBOUNDED PRECEDING", offset)
+		}
+	}
+
+	return frame
+}
+
+// FrameBound parses UNBOUNDED PRECEDING, n PRECEDING, CURRENT ROW,
+// n FOLLOWING or UNBOUNDED FOLLOWING, returning the bound and its offset.
+func (p *Parser) FrameBound() (ast.FrameBound, int) {
+	if p.isWord("current") {
+		offset := p.matchWord("current")
+		p.matchWord("row")
+
+		return ast.FrameBound{Kind: ast.CurrentRow}, offset
+	}
+
+	unbounded := p.isWord("unbounded")
+
+	var bound ast.FrameBound
+	var offset int
+	if unbounded {
+		offset = p.matchWord("unbounded")
+	} else {
+		var text string
+		text, offset = p.matchOffset(lexer.Integer)
+
+		rows, err := strconv.Atoi(text)
+		if err != nil {
+			p.error(err.Error(), offset)
+		}
+		bound.Offset = rows
+	}
+
+	switch {
+	case p.isWord("preceding"):
+		p.matchWord("preceding")
+		bound.Kind = ast.Preceding
+		if unbounded {
+			bound.Kind = ast.UnboundedPreceding
+		}
+
+	default:
+		p.matchWord("following")
+		bound.Kind = ast.Following
+		if unbounded {
+			bound.Kind = ast.UnboundedFollowing
+		}
+	}
+
+	return bound, offset
+}
+
+// CallArguments parses a comma separated list of arguments, up to and
+// including the closing parenthesis.
+func (p *Parser) CallArguments() []ast.Expr {
+	var args []ast.Expr
+	if p.s.Peek() != lexer.CloseParenthesis {
+		args = append(args, p.Expression(1))
+		for p.s.Peek() == lexer.Comma {
+			p.match(lexer.Comma)
+			args = append(args, p.Expression(1))
+		}
+	}
+	p.match(lexer.CloseParenthesis)
+
+	return args
+}
+
 func (p *Parser) UnaryExpression() ast.Expr {
 	token := p.s.Peek()
 
@@ -231,7 +925,7 @@ func (p *Parser) UnaryExpression() ast.Expr {
 	case lexer.OpenParenthesis:
 		p.match(lexer.OpenParenthesis)
 
-		if p.s.Peek() == lexer.Select {
+		if token := p.s.Peek(); token == lexer.Select || token == lexer.With {
 			subselect := p.Subselect()
 			p.match(lexer.CloseParenthesis)
 
@@ -247,11 +941,51 @@ func (p *Parser) UnaryExpression() ast.Expr {
 
 		return paren
 
+	case lexer.Not:
+		_, offset := p.matchOffset(lexer.Not)
+		expr := p.Expression(ast.Operator(lexer.Not).Precedence())
+
+		return &ast.UnaryExpr{Op: ast.Operator(lexer.Not), Expr: expr, Offset: offset}
+
+	case lexer.Subtract:
+		_, offset := p.matchOffset(lexer.Subtract)
+
+		// negative numeric literals
+		switch p.s.Peek() {
+		case lexer.Integer:
+			text, offset := p.matchOffset(lexer.Integer)
+			num, err := strconv.ParseInt("-"+text, 10, 64)
+			if err != nil {
+				p.error("integer out of range", offset)
+			}
+			return &ast.Integer{Val: num}
+
+		case lexer.Float:
+			num, _ := strconv.ParseFloat(p.match(lexer.Float), 64)
+			return &ast.Float{Val: -num}
+		}
+
+		return &ast.UnaryExpr{Op: ast.Operator(lexer.Subtract), Expr: p.UnaryExpression(), Offset: offset}
+
+	case lexer.Case:
+		return p.CaseExpression()
+
+	case lexer.OpenBrace:
+		return p.ObjectLiteral()
+
+	case lexer.OpenBracket:
+		return p.ArrayLiteral()
+
 	case lexer.String:
-		return &ast.String{Val: p.match(lexer.String)}
+		text := p.match(lexer.String)
+		return &ast.String{Val: text, Offset: p.s.Start()}
 
 	case lexer.Integer:
-		num, _ := strconv.Atoi(p.match(lexer.Integer))
+		text, offset := p.matchOffset(lexer.Integer)
+		num, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			p.error("integer out of range", offset)
+		}
 		return &ast.Integer{Val: num}
 
 	case lexer.Float:
@@ -267,48 +1001,90 @@ func (p *Parser) UnaryExpression() ast.Expr {
 		return &ast.Boolean{Val: false}
 
 	case lexer.Ident:
-		name := p.match(lexer.Ident)
-		ref := &ast.Reference{Name: name}
+		return p.IdentExpression(p.matchOffset(lexer.Ident))
+
+	case lexer.Parameter, lexer.Colon:
+		param := p.Parameter()
 		if p.s.Peek() == lexer.Arrow {
-			ref.PathExpr = p.PathExpression()
+			param.PathExpr = p.PathExpression()
 		}
 
-		return ref
+		return param
 
-	case lexer.JsonPath:
-		p.match(lexer.JsonPath)
-		p.match(lexer.OpenParenthesis)
-		expr := p.Expression(1)
-		p.match(lexer.Comma)
-		path := p.match(lexer.String)
-		p.match(lexer.CloseParenthesis)
+	default:
+		_, offset, _ := p.s.Scan()
+		p.error("unexpected token in expression", offset)
+	}
 
-		jsonpath := &ast.JsonPath{Expr: expr, Path: path}
-		if p.s.Peek() == lexer.Arrow {
-			jsonpath.PathExpr = p.PathExpression()
+	return nil
+}
+
+// Parameter parses a positional parameter, $1, or a named parameter, :name. A
+// colon can't start an expression otherwise, so named parameters aren't
+// confused with the colons of object literals and slices.
+func (p *Parser) Parameter() *ast.Parameter {
+	param := &ast.Parameter{}
+
+	if p.s.Peek() == lexer.Parameter {
+		name, offset := p.matchOffset(lexer.Parameter)
+		if n, err := strconv.Atoi(name[1:]); err != nil || n < 1 {
+			p.error(fmt.Sprintf("invalid parameter %v", name), offset)
 		}
+		param.Name, param.Offset = name, offset
+	} else {
+		p.match(lexer.Colon)
+		name, offset := p.matchOffset(lexer.Ident)
+		param.Name, param.Offset = ":"+name, offset
+	}
 
-		return jsonpath
+	p.Parameters = append(p.Parameters, param)
 
-	case lexer.Jq:
-		p.match(lexer.Jq)
-		p.match(lexer.OpenParenthesis)
-		expr := p.Expression(1)
-		p.match(lexer.Comma)
-		path := p.match(lexer.String)
-		p.match(lexer.CloseParenthesis)
+	return param
+}
 
-		jq := &ast.JQ{Expr: expr, Path: path}
-		if p.s.Peek() == lexer.Arrow {
-			jq.PathExpr = p.PathExpression()
+// IdentExpression parses the expression starting with an identifier: a
+// function call, a typed literal, current_timestamp or a reference.
+func (p *Parser) IdentExpression(name string, offset int) ast.Expr {
+	switch p.s.Peek() {
+	case lexer.OpenParenthesis:
+		return p.Call(name, offset)
+	case lexer.String:
+		// other names followed by a string are references with an alias
+		if strings.EqualFold(name, "interval") || strings.EqualFold(name, "timestamp") {
+			return p.TypedLiteral(name)
 		}
+	}
+
+	// current_timestamp is SQL's now(), without parentheses
+	if strings.EqualFold(name, "current_timestamp") {
+		return &ast.Call{Name: "now", Func: ast.LookupFunction("now"), Offset: offset}
+	}
 
-		return jq
+	ref := &ast.Reference{Name: name}
+	if p.s.Peek() == lexer.Arrow {
+		ref.PathExpr = p.PathExpression()
+	}
 
-	default:
-		_, offset, _ := p.s.Scan()
-		p.error("unexpected token in expression", offset)
+	return ref
+}
+
+// TypedLiteral parses a literal of a named type, such as interval '7d' or
+// timestamp '2018-01-01T00:00:00Z'. The type names aren't keywords, so they
+// can still be used as references and field names.
+func (p *Parser) TypedLiteral(name string) ast.Expr {
+	text, textOffset := p.matchOffset(lexer.String)
+
+	if strings.EqualFold(name, "interval") {
+		d, err := ast.ParseDuration(text)
+		if err != nil {
+			p.error(err.Error(), textOffset)
+		}
+		return &ast.Interval{Val: d}
 	}
 
-	return nil
+	t, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		p.error(fmt.Sprintf("invalid timestamp %q", text), textOffset)
+	}
+	return &ast.Timestamp{Val: t.UTC()}
 }
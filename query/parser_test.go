@@ -0,0 +1,186 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saracen/kubeql/query/ast"
+)
+
+func TestParse(t *testing.T) {
+	tests := []string{
+		"select pods from pods",
+		"SELECT pods->metadata->name AS name FROM pods NAMESPACE default",
+		"select deployments->metadata->name as deployment_name, pods->metadata->name as pod_name FROM apps/v1beta1/deployments, pods where pods->metadata->labels->app = deployments->metadata->labels->app",
+		"select pods->spec->containers->0->image, pods->spec->containers->-1, pods->spec->containers->*->name, pods->spec->containers->1:2 from pods",
+		"select pods->metadata->labels->(pods->metadata->name) from pods",
+		"select not true, -pods->a, 'a' || 'b', 1 + 2 * 3 % 4 - 5 / 6 from pods",
+		"select pods from pods where pods->a in (1, 2) and pods->b not between 1 and 2 or pods->c ilike 'a%' or pods->d !~* '^a'",
+		"select case when pods->a > 1 then 'big' when pods->a = 1 then 'one' else 'small' end from pods",
+		"select case pods->a when 1 then 'one' end from pods",
+		"select count(*), sum(pods->a), lower(pods->b) from pods",
+		"select jq(pods, '.metadata'), jsonpath(pods, '{.metadata}'), cel(pods, 'self.metadata') from pods",
+		"select timestamp '2018-01-01T00:00:00Z', interval '1h30m', now() - interval '7d' from pods",
+		"select {'a': 1, \"b\": [1, 2.5, null]}, pods->a @> {'a': 1}, pods->a ? 'b', pods->a ?| ['a'] from pods",
+		"select x->value, x->ordinality from pods, unnest(pods->spec->containers) with ordinality as x",
+		"select c from pods, lateral (select pods->a as c from pods p2) as c",
+		"select p from (select pods as p from pods) as q",
+		"select (select count(*) from pods) from pods",
+		"with t as (select pods->a as a from pods) select t->a from t",
+		"with recursive t as (select 1 as n from pods union all select t->n + 1 from t where t->n < 3) select t->n from t",
+		"select pods from pods union select pods from pods intersect select pods from pods except all select pods from pods",
+		"select row_number() over (partition by pods->a order by pods->b desc rows between 1 preceding and current row) from pods",
+		"select sum(pods->a) over (order by pods->b rows between unbounded preceding and unbounded following) from pods",
+		"select *, pods.* from pods",
+		"select pods from pods namespace $1 where pods->a = :name and pods->b = $2 limit :n",
+		"select pods from pods limit 10",
+		"select pods from pods union all select pods from pods limit 1",
+		"with q as (select pods from pods limit 1) select q from q",
+		"select .5, 1.5, 10 from pods",
+		"select current_timestamp, now() - current_timestamp from pods",
+	}
+
+	for _, test := range tests {
+		if _, err := NewStringParser(test).Parse(); err != nil {
+			t.Errorf("%q: %v", test, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"from pods", "Expected SELECT"},
+		{"select from pods", "unexpected token in expression"},
+		{"select pods from pods where", "unexpected token in expression"},
+		{"select unknown(1) from pods", "unknown function unknown()"},
+		{"select lower() from pods", "lower()"},
+		{"select x from pods, unknown(1) as x", "unknown set-returning function unknown()"},
+		{"select row_number() from pods", "window function row_number() requires an OVER clause"},
+		{"select interval 'abc' from pods", "(offset: 21)"},
+		{"select timestamp 'abc' from pods", `invalid timestamp "abc"`},
+		{"select 99999999999999999999 from pods", "integer out of range"},
+		{"select {'a': 1, 'a': 2} from pods", `duplicate key "a" in object`},
+		{"select pods not 1 from pods", "expected IN, BETWEEN, LIKE or ILIKE after NOT"},
+		{"select $0 from pods", "invalid parameter $0"},
+		{"select pods from pods limit 'a'", "unexpected token"},
+		{"select pods from pods limit 99999999999999999999", "integer out of range"},
+		{"with recursive t as (select pods from pods union select t from t limit 1) select t from t", "LIMIT is not supported in a recursive query"},
+		{"select jsonpath_value(pods, '{bad') from pods", `(offset: 28) ("select jsonpath_value(pods, " <)`},
+		{"select format('%d %z', 1, 2) from pods", `format() unsupported verb "%z" (offset: 14)`},
+		{"select sum(pods) over (rows between unbounded following and current row) from pods", "frame start cannot be UNBOUNDED FOLLOWING"},
+	}
+
+	for _, test := range tests {
+		_, err := NewStringParser(test.query).Parse()
+		if err == nil {
+			t.Errorf("%q: expected error %q", test.query, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got error %q, expected %q", test.query, err, test.err)
+		}
+	}
+}
+
+func TestParseSelectExpressions(t *testing.T) {
+	ts, _ := time.Parse(time.RFC3339, "2018-01-01T00:00:00Z")
+
+	tests := []struct {
+		query   string
+		aliases []string
+		exprs   []ast.Expr
+	}{
+		{
+			// a string after a name is an alias, unless the name is a type
+			query:   "select pods 'p', pods \"q\" from pods",
+			aliases: []string{"p", "q"},
+			exprs:   []ast.Expr{&ast.Reference{Name: "pods"}, &ast.Reference{Name: "pods"}},
+		},
+		{
+			query:   "select interval '1h' 'i', INTERVAL '2m', timestamp '2018-01-01T00:00:00Z' as t from pods",
+			aliases: []string{"i", "", "t"},
+			exprs:   []ast.Expr{&ast.Interval{Val: ast.Duration(time.Hour)}, &ast.Interval{Val: ast.Duration(2 * time.Minute)}, &ast.Timestamp{Val: ts}},
+		},
+		{
+			// interval and timestamp are references when not followed by a
+			// string
+			query:   "select interval, timestamp from pods",
+			aliases: []string{"", ""},
+			exprs:   []ast.Expr{&ast.Reference{Name: "interval"}, &ast.Reference{Name: "timestamp"}},
+		},
+		{
+			query:   "select 1 one, 2.5 as two, 'three' from pods",
+			aliases: []string{"one", "two", ""},
+			exprs:   []ast.Expr{&ast.Integer{Val: 1}, &ast.Float{Val: 2.5}, &ast.String{Val: "three", Offset: 26}},
+		},
+	}
+
+	for _, test := range tests {
+		s, err := NewStringParser(test.query).Parse()
+		if err != nil {
+			t.Errorf("%q: %v", test.query, err)
+			continue
+		}
+
+		var aliases []string
+		var exprs []ast.Expr
+		for _, expr := range s.SelectClause.Expressions {
+			aliases = append(aliases, expr.Alias)
+			exprs = append(exprs, expr.Condition)
+		}
+
+		if !reflect.DeepEqual(aliases, test.aliases) {
+			t.Errorf("%q: aliases are %q, expected %q", test.query, aliases, test.aliases)
+		}
+		if !reflect.DeepEqual(exprs, test.exprs) {
+			t.Errorf("%q: expressions are %#v, expected %#v", test.query, exprs, test.exprs)
+		}
+	}
+}
+
+func TestParseParameters(t *testing.T) {
+	query := "select pods from pods namespace :ns where pods->a = $1 and pods->b = :ns limit $2"
+
+	parser := NewStringParser(query)
+	s, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, param := range parser.Parameters {
+		names = append(names, param.Name)
+	}
+	if expected := []string{":ns", "$1", ":ns", "$2"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("parameters are %q, expected %q", names, expected)
+	}
+
+	if param := s.FromClause.Resources[0].NamespaceParam; param == nil || param.Name != ":ns" {
+		t.Errorf("NAMESPACE parameter is %#v, expected :ns", param)
+	}
+	if s.Limit == nil || s.Limit.Param == nil || s.Limit.Param.Name != "$2" {
+		t.Errorf("LIMIT is %#v, expected $2", s.Limit)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	s, err := NewStringParser("select pods from pods union select pods from pods limit 5").Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Compound == nil {
+		t.Fatal("expected a compound statement")
+	}
+	if s.Limit == nil || s.Limit.Count != 5 {
+		t.Errorf("LIMIT is %#v, expected 5 on the compound statement", s.Limit)
+	}
+	if s.Compound.Right.Limit != nil {
+		t.Errorf("LIMIT applied to the right-hand statement rather than the compound statement")
+	}
+}
@@ -0,0 +1,202 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/saracen/kubeql/query/ast"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// Prepared is a parsed and checked query, which can be executed with
+// different parameters. It isn't safe for concurrent use.
+type Prepared struct {
+	config  *rest.Config
+	query   string
+	options Options
+
+	stmt       *ast.SelectStatement
+	parameters []*ast.Parameter
+}
+
+// NamedParam is the value of a named parameter, :name, passed to Execute.
+type NamedParam struct {
+	Name  string
+	Value interface{}
+}
+
+// Named returns the value of a named parameter, for passing to Execute.
+func Named(name string, value interface{}) NamedParam {
+	return NamedParam{Name: name, Value: value}
+}
+
+// Prepare parses and checks a query, ready to be executed.
+func Prepare(c *rest.Config, query string, options Options) (*Prepared, error) {
+	parser := NewStringParser(query)
+
+	s, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if !options.Permissive {
+		if err := checkSelectStatement(s); err != nil {
+			return nil, locateError(query, err)
+		}
+	}
+
+	return &Prepared{
+		config:     c,
+		query:      query,
+		options:    options,
+		stmt:       s,
+		parameters: parser.Parameters,
+	}, nil
+}
+
+// Execute executes the query. Positional parameters, $1, $2 and so on, take
+// the values of params in order, and named parameters, :name, take the values
+// of params returned by Named. Every parameter must have a value, and every
+// value a parameter.
+func (p *Prepared) Execute(params ...interface{}) (*Results, error) {
+	data, err := p.bind(params)
+	if err != nil {
+		return nil, err
+	}
+	data[ast.NowKey] = time.Now().UTC()
+
+	session := &Session{
+		pool:      dynamic.NewDynamicClientPool(p.config),
+		resources: make(map[resourceKey]*unstructured.UnstructuredList),
+		options:   p.options,
+		tables:    make(map[string]*Results),
+	}
+	defer func() {
+		ast.Unfold(session.folded)
+	}()
+
+	results, err := executeSelectStatement(session, p.stmt, data)
+	if err != nil {
+		return nil, locateError(p.query, err)
+	}
+
+	return results, nil
+}
+
+// bind returns the values of the query's parameters, keyed by the parameters
+// as they're written.
+func (p *Prepared) bind(params []interface{}) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+
+	positional := 0
+	for _, param := range params {
+		name := ""
+		val := param
+		if named, ok := param.(NamedParam); ok {
+			name, val = ":"+named.Name, named.Value
+		} else {
+			positional++
+			name = "$" + strconv.Itoa(positional)
+		}
+
+		if _, ok := data[name]; ok {
+			return nil, fmt.Errorf("parameter %v given more than once", name)
+		}
+
+		converted, err := paramValue(val)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %v: %v", name, err)
+		}
+		data[name] = converted
+	}
+
+	used := make(map[string]bool)
+	for _, param := range p.parameters {
+		if _, ok := data[param.Name]; !ok {
+			return nil, locateError(p.query, &ast.EvalError{Offset: param.Offset, Msg: fmt.Sprintf("no value for parameter %v", param.Name)})
+		}
+		used[param.Name] = true
+	}
+
+	for name := range data {
+		if !used[name] {
+			return nil, fmt.Errorf("parameter %v isn't used by the query", name)
+		}
+	}
+
+	return data, nil
+}
+
+// paramValue converts the value of a parameter to the types of the values of
+// resources: integers are int64, other numbers float64, and values other than
+// strings, booleans, timestamps, arrays and objects are converted through JSON.
+func paramValue(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case nil, bool, string, int64, float64, time.Time:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case float32:
+		return float64(v), nil
+
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, elem := range v {
+			converted, err := paramValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = converted
+		}
+		return arr, nil
+
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			converted, err := paramValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = converted
+		}
+		return obj, nil
+	}
+
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return paramValue(decoded)
+}
@@ -0,0 +1,133 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saracen/kubeql/query/ast"
+)
+
+func TestPreparedExecuteTwice(t *testing.T) {
+	tests := []struct {
+		query    string
+		params   [][]interface{}
+		expected [][]interface{}
+	}{
+		{
+			query:    "select x from unnest($1) as x",
+			params:   [][]interface{}{{[]interface{}{1, 2}}, {[]interface{}{"a"}}},
+			expected: [][]interface{}{{int64(1), int64(2)}, {"a"}},
+		},
+		{
+			query:    "select upper(:s) from unnest([1]) as x",
+			params:   [][]interface{}{{Named("s", "a")}, {Named("s", "b")}},
+			expected: [][]interface{}{{"A"}, {"B"}},
+		},
+		{
+			// aggregates start over for each execution
+			query:    "select count(x) + max(x) from unnest($1) as x",
+			params:   [][]interface{}{{[]interface{}{1, 5}}, {[]interface{}{2}}},
+			expected: [][]interface{}{{int64(7)}, {int64(3)}},
+		},
+		{
+			query:    "select x from unnest([1, 2, 3]) as x limit $1",
+			params:   [][]interface{}{{2}, {0}},
+			expected: [][]interface{}{{int64(1), int64(2)}, nil},
+		},
+		{
+			query:    "select pods->metadata->name from pods namespace :ns",
+			params:   [][]interface{}{{Named("ns", "a")}, {Named("ns", "b")}},
+			expected: [][]interface{}{{"web-1", "web-2"}, {"db-1"}},
+		},
+		{
+			// the subselect lists every namespace, whichever namespace the
+			// outer statement lists
+			query:    "select (select count(*) from pods as q where q->metadata->namespace = :ns) from pods namespace b",
+			params:   [][]interface{}{{Named("ns", "a")}, {Named("ns", "b")}},
+			expected: [][]interface{}{{int64(2)}, {int64(1)}},
+		},
+		{
+			query:    "select (select count(*) from pods namespace :ns as q) from pods namespace b",
+			params:   [][]interface{}{{Named("ns", "a")}, {Named("ns", "b")}},
+			expected: [][]interface{}{{int64(2)}, {int64(1)}},
+		},
+	}
+
+	for _, test := range tests {
+		prepared := prepareTest(t, test.query, Options{})
+		for i, params := range test.params {
+			results, err := prepared.Execute(params...)
+			if err != nil {
+				t.Errorf("%q, execution %d: %v", test.query, i+1, err)
+				continue
+			}
+
+			if values := column(results, 0); !reflect.DeepEqual(values, test.expected[i]) {
+				t.Errorf("%q, execution %d: got %#v, expected %#v", test.query, i+1, values, test.expected[i])
+			}
+		}
+	}
+}
+
+func TestPreparedExecuteNow(t *testing.T) {
+	prepared := prepareTest(t, "select now(), now() = now(), now() - now(), current_timestamp = now(), age(now()), (select now() from unnest([1]) as y) from unnest([1, 2]) as x", Options{})
+
+	// every call of now(), and every row, sees the same time
+	expected := []interface{}{true, ast.Duration(0), true, ast.Duration(0)}
+
+	var times []interface{}
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		results, err := prepared.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values := column(results, 0)
+		if len(values) != 2 || values[0] != values[1] {
+			t.Fatalf("execution %d: now() is %v", i+1, values)
+		}
+		times = append(times, values[0])
+
+		for _, row := range results.Rows {
+			if !reflect.DeepEqual(row.Columns[1:5], expected) {
+				t.Errorf("execution %d: got %v, expected %v", i+1, row.Columns[1:5], expected)
+			}
+			if row.Columns[5] != values[0] {
+				t.Errorf("execution %d: now() is %v in a subselect, expected %v", i+1, row.Columns[5], values[0])
+			}
+		}
+	}
+
+	// and changes between executions
+	if times[0] == times[1] {
+		t.Errorf("now() is %v for both executions", times[0])
+	}
+}
+
+func TestPreparedExecuteErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		params []interface{}
+		err    string
+	}{
+		{"select $1 from unnest([1]) as x", nil, "no value for parameter $1"},
+		{"select :name from unnest([1]) as x", []interface{}{Named("other", 1)}, "no value for parameter :name"},
+		{"select $1 from unnest([1]) as x", []interface{}{1, 2}, "parameter $2 isn't used by the query"},
+		{"select :a from unnest([1]) as x", []interface{}{Named("a", 1), Named("a", 2)}, "parameter :a given more than once"},
+		{"select x from unnest([1]) as x limit $1", []interface{}{"1"}, "LIMIT expects an integer, got string"},
+		{"select x from unnest([1]) as x limit $1", []interface{}{-1}, "LIMIT must not be negative"},
+	}
+
+	for _, test := range tests {
+		_, err := executeTest(t, test.query, test.params...)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got error %v, expected %q", test.query, err, test.err)
+		}
+	}
+}